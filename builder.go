@@ -0,0 +1,63 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import "errors"
+
+// PacketBuilder builds a Packet step by step, keeping Len consistent
+// with Data:
+//
+//	p, err := NewPacketBuilder().WithIndex(1).WithType(PacketTypeUnicast).WithData(frame).Build()
+type PacketBuilder struct {
+	p Packet
+}
+
+// NewPacketBuilder returns a builder of an empty unicast packet
+func NewPacketBuilder() *PacketBuilder {
+	return &PacketBuilder{p: Packet{PacketType: PacketTypeUnicast, Data: []byte{}}}
+}
+
+// WithIndex sets the interface index where the frame was received
+func (b *PacketBuilder) WithIndex(index uint16) *PacketBuilder {
+	b.p.Index = index
+	return b
+}
+
+// WithType sets the packet type, one of the PacketType constants
+func (b *PacketBuilder) WithType(pt uint8) *PacketBuilder {
+	b.p.PacketType = pt
+	return b
+}
+
+// WithTimestamp sets the timestamp, see Packet.Timestamp
+func (b *PacketBuilder) WithTimestamp(ts uint32) *PacketBuilder {
+	b.p.Timestamp = ts
+	return b
+}
+
+// WithData sets the packet data and its length, data is not copied
+func (b *PacketBuilder) WithData(data []byte) *PacketBuilder {
+	b.p.Data = data
+	b.p.Len = uint32(len(data))
+	return b
+}
+
+// WithFlags sets the user defined flags of the packet
+func (b *PacketBuilder) WithFlags(flags uint16) *PacketBuilder {
+	b.p.Flags = flags
+	return b
+}
+
+// Build returns the packet, checking that its type is defined and that
+// it fits into MaxSnapLength. The snap length of a particular file may be
+// smaller, which WritePacket checks.
+func (b *PacketBuilder) Build() (Packet, error) {
+	if !isValidPacketType(b.p.PacketType) {
+		return Packet{}, errors.New("cannot build packet, packet type is undefined")
+	}
+	if len(b.p.Data)+minPacketSize > MaxSnapLength {
+		return Packet{}, errors.New("cannot build packet, length of packet greater than maximum snap length")
+	}
+	return b.p, nil
+}
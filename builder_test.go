@@ -0,0 +1,38 @@
+package lpcap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacketBuilder(t *testing.T) {
+	p, err := NewPacketBuilder().
+		WithIndex(300).
+		WithType(PacketTypeBroadcast).
+		WithTimestamp(42).
+		WithData([]byte{1, 2, 3}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, Packet{Index: 300, PacketType: PacketTypeBroadcast, Timestamp: 42, Len: 3, Data: []byte{1, 2, 3}}, p)
+
+	pcap := NewMemory()
+	_, err = pcap.WritePacket(p)
+	assert.NoError(t, err)
+	packets, err := pcap.ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, packets, 1) {
+		assert.Equal(t, p.Index, packets[0].Index)
+		assert.Equal(t, p.Data, packets[0].Data)
+	}
+
+	p, err = NewPacketBuilder().Build()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), p.Len)
+	_, err = NewPacketBuilder().WithType(3).Build()
+	assert.Error(t, err)
+	_, err = NewPacketBuilder().WithData(make([]byte, MaxSnapLength)).Build()
+	assert.Error(t, err)
+}
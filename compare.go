@@ -0,0 +1,61 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"bytes"
+	"reflect"
+)
+
+// Equal reports whether captures a and b have identical file headers and
+// packets. If they differ, the index of the first differing packet is
+// returned, counted from 0, or -1 if the file headers differ, otherwise
+// the index is 0. A capture having more packets than the other differs at
+// the first extra packet.
+// Both captures are read from the beginning in lockstep, one packet at
+// a time, and their read offsets are not moved.
+func Equal(a, b *PCAP) (bool, int, error) {
+	ca, err := a.rewound()
+	if err != nil {
+		return false, 0, err
+	}
+	cb, err := b.rewound()
+	if err != nil {
+		return false, 0, err
+	}
+	if !reflect.DeepEqual(*ca.h, *cb.h) {
+		return false, -1, nil
+	}
+
+	pa := &Packet{Data: []byte{}}
+	pb := &Packet{Data: []byte{}}
+	for i := 0; ; i++ {
+		nextA, nextB := ca.Next(), cb.Next()
+		if !nextA && !nextB {
+			return true, 0, nil
+		}
+		if !nextA || !nextB {
+			return false, i, nil
+		}
+		if _, err := ca.readPacket(pa, pa.Data); err != nil {
+			return false, i, err
+		}
+		if _, err := cb.readPacket(pb, pb.Data); err != nil {
+			return false, i, err
+		}
+		if !equalPackets(pa, pb) {
+			return false, i, nil
+		}
+	}
+}
+
+// equalPackets reports whether headers and data of packets are identical
+func equalPackets(a, b *Packet) bool {
+	return a.Index == b.Index &&
+		a.PacketType == b.PacketType &&
+		a.Timestamp == b.Timestamp &&
+		a.Len == b.Len &&
+		a.Flags == b.Flags &&
+		bytes.Equal(a.Data, b.Data)
+}
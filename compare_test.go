@@ -0,0 +1,51 @@
+package lpcap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEqual(t *testing.T) {
+	write := func(pcap *PCAP, n int, change int) *PCAP {
+		for i := 0; i < n; i++ {
+			data := []byte{byte(i), 1, 2}
+			if i == change {
+				data[2] = 3
+			}
+			_, err := pcap.WritePacket(Packet{Index: uint16(i), PacketType: PacketTypeUnicast, Len: 3, Data: data})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return pcap
+	}
+
+	a := write(NewMemory(), 5, -1)
+	b := write(NewMemory(), 5, -1)
+	p := new(Packet)
+	if _, err := a.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	offset := a.offset
+
+	equal, _, err := Equal(a, b)
+	assert.NoError(t, err)
+	assert.True(t, equal)
+	assert.Equal(t, offset, a.offset)
+
+	equal, i, err := Equal(a, write(NewMemory(), 5, 3))
+	assert.NoError(t, err)
+	assert.False(t, equal)
+	assert.Equal(t, 3, i)
+
+	equal, i, err = Equal(write(NewMemory(), 4, -1), a)
+	assert.NoError(t, err)
+	assert.False(t, equal)
+	assert.Equal(t, 4, i)
+
+	equal, i, err = Equal(a, write(NewMemory(WithLinkType(LinkTypeEthernet80211)), 5, -1))
+	assert.NoError(t, err)
+	assert.False(t, equal)
+	assert.Equal(t, -1, i)
+}
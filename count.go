@@ -0,0 +1,87 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"fmt"
+	"sync/atomic"
+)
+
+// Count returns the count of packets in the file. It is taken from the
+// file header if it stores the count, see WithPacketCount, or from the
+// summary footer. The header count is trusted only if the file still ends
+// where it ended when the count was stored, otherwise, for example after
+// a crash before Close, headers of all packets are scanned. The read
+// offset is not moved.
+func (pcap *PCAP) Count() (int, error) {
+	fh := pcap.h
+	if pcap.writable && fh.flags&FlagPacketCount != 0 {
+		// maintained by WritePacket
+		return int(fh.packetCount), nil
+	}
+	if !pcap.writable {
+		// the active header of concatenated captures may be a following one
+		var err error
+		if fh, err = readFileHeader(pcap.rd, 0, atomic.LoadInt64(&pcap.fsize)); err != nil {
+			return 0, err
+		}
+	}
+	if fh.flags&FlagPacketCount != 0 && fh.countEnd == atomic.LoadInt64(&pcap.fsize) {
+		return int(fh.packetCount), nil
+	}
+	if pcap.footer != nil {
+		return pcap.footer.Count, nil
+	}
+	return pcap.scanCount()
+}
+
+// FixCount scans headers of all packets and rewrites the packet count
+// stored in the file header, see WithPacketCount
+func (pcap *PCAP) FixCount() error {
+	if !pcap.writable {
+		return fmt.Errorf("cannot call FixCount, file is opened read-only: %w", ErrUnsupportedOperation)
+	}
+	if pcap.h.flags&FlagPacketCount == 0 {
+		return fmt.Errorf("cannot call FixCount, file header does not store the packet count: %w", ErrUnsupportedOperation)
+	}
+	n, err := pcap.scanCount()
+	if err != nil {
+		return err
+	}
+	pcap.h.packetCount = int64(n)
+	return pcap.writeCount()
+}
+
+// VerifyCount scans headers of all packets and checks that the file
+// contains the expected count of packets, such as the count declared by
+// the summary footer or by a sidecar. A different count is reported by an
+// error wrapping ErrCountMismatch, which catches truncated or padded
+// files. The read offset is not moved.
+func (pcap *PCAP) VerifyCount(expected int) error {
+	n, err := pcap.scanCount()
+	if err != nil {
+		return err
+	}
+	if n != expected {
+		return fmt.Errorf("file contains %d packets, expected %d: %w", n, expected, ErrCountMismatch)
+	}
+	return nil
+}
+
+// scanCount counts packets by scanning their headers
+func (pcap *PCAP) scanCount() (int, error) {
+	n := 0
+	err := pcap.scan(func(PacketInfo) error {
+		n++
+		return nil
+	})
+	return n, err
+}
+
+// writeCount rewrites the file header with the packet count
+// of the written packets
+func (pcap *PCAP) writeCount() error {
+	pcap.h.countEnd = atomic.LoadInt64(&pcap.fsize)
+	return pcap.writeHeader()
+}
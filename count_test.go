@@ -0,0 +1,118 @@
+package lpcap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "count")
+	pcap, err := Create(path, WithPacketCount())
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(pcap *PCAP, n int) {
+		for i := 0; i < n; i++ {
+			_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{byte(i)}})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	count := func() int {
+		pcap, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pcap.Close()
+		n, err := pcap.Count()
+		assert.NoError(t, err)
+		return n
+	}
+
+	assert.Equal(t, 0, count())
+	write(pcap, 3)
+	n, err := pcap.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	// stale header count is not trusted
+	assert.Equal(t, 3, count())
+	assert.NoError(t, pcap.Close())
+	assert.Equal(t, 3, count())
+
+	pcap, err = OpenRW(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write(pcap, 2)
+	assert.NoError(t, pcap.FixCount())
+	rd, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(5), rd.h.packetCount)
+	rd.Close()
+	write(pcap, 1)
+	assert.NoError(t, pcap.Close())
+	assert.Equal(t, 6, count())
+
+	// the stored count is used without scanning
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, _, err := unmarshalFileHeader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.packetCount = 42
+	copy(b, marshalFileHeader(h))
+	rd, err = OpenMemory(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = rd.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 42, n)
+}
+
+func TestCountWithoutHeaderCount(t *testing.T) {
+	pcap := createSequence(t, 4)
+	defer pcap.Close()
+	n, err := pcap.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.ErrorIs(t, pcap.FixCount(), ErrUnsupportedOperation)
+}
+
+func TestVerifyCount(t *testing.T) {
+	pcap := NewMemory(WithSummaryFooter())
+	for i := 0; i < 3; i++ {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{byte(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.NoError(t, pcap.Close())
+
+	rd, err := OpenMemory(pcap.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := rd.Summary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, rd.VerifyCount(s.Count))
+
+	err = rd.VerifyCount(4)
+	assert.ErrorIs(t, err, ErrCountMismatch)
+	assert.ErrorContains(t, err, "contains 3 packets, expected 4")
+	// the read offset is not moved
+	packets, err := rd.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 3)
+}
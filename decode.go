@@ -0,0 +1,58 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+// LinkFrame is the link layer header decoded from packet data
+type LinkFrame struct {
+	// Source hardware address
+	Src net.HardwareAddr
+	// Destination hardware address
+	Dst net.HardwareAddr
+	// Type of the payload protocol, such as EtherType
+	Type uint16
+	// Data following the link layer header
+	Payload []byte
+}
+
+// Decoder decodes the link layer header of a link type
+type Decoder interface {
+	Decode(data []byte) (LinkFrame, error)
+}
+
+var (
+	decodersMx sync.RWMutex
+	decoders   = map[LinkType]Decoder{
+		LinkTypeEthernet2: EthernetDecoder{},
+	}
+)
+
+// RegisterDecoder sets the decoder used by Packet.Decode for the link
+// type, replacing the previous one. A nil decoder removes it.
+func RegisterDecoder(lt LinkType, d Decoder) {
+	decodersMx.Lock()
+	defer decodersMx.Unlock()
+	if d == nil {
+		delete(decoders, lt)
+		return
+	}
+	decoders[lt] = d
+}
+
+// Decode decodes the link layer header of the packet data by the decoder
+// registered for the link type, usually the link type of the file
+func (p Packet) Decode(lt LinkType) (LinkFrame, error) {
+	decodersMx.RLock()
+	d, ok := decoders[lt]
+	decodersMx.RUnlock()
+	if !ok {
+		return LinkFrame{}, fmt.Errorf("no decoder registered for link type %d", lt)
+	}
+	return d.Decode(p.Data)
+}
@@ -0,0 +1,51 @@
+package lpcap
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type decoderFunc func(data []byte) (LinkFrame, error)
+
+func (f decoderFunc) Decode(data []byte) (LinkFrame, error) {
+	return f(data)
+}
+
+func TestDecode(t *testing.T) {
+	frame := []byte{
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x02, // dst
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x01, // src
+		0x86, 0xdd, // IPv6
+		0x60, 0x00,
+	}
+	p := Packet{PacketType: PacketTypeUnicast, Len: uint32(len(frame)), Data: frame}
+
+	f, err := p.Decode(LinkTypeEthernet2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, LinkFrame{
+		Dst:     net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x02},
+		Src:     net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+		Type:    0x86dd,
+		Payload: []byte{0x60, 0x00},
+	}, f)
+
+	_, err = Packet{Data: frame[:10]}.Decode(LinkTypeEthernet2)
+	assert.Error(t, err)
+	_, err = p.Decode(LinkTypeEthernet80211)
+	assert.Error(t, err)
+
+	errStub := errors.New("stub")
+	RegisterDecoder(LinkTypeEthernet80211, decoderFunc(func([]byte) (LinkFrame, error) {
+		return LinkFrame{}, errStub
+	}))
+	_, err = p.Decode(LinkTypeEthernet80211)
+	assert.ErrorIs(t, err, errStub)
+	RegisterDecoder(LinkTypeEthernet80211, nil)
+	_, err = p.Decode(LinkTypeEthernet80211)
+	assert.NotErrorIs(t, err, errStub)
+}
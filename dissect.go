@@ -0,0 +1,53 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DissectResult is the structured data parsed from packet data
+type DissectResult struct {
+	// Link layer header
+	Link LinkFrame
+	// Data of upper layers parsed by the dissector, if any
+	Extra any
+}
+
+// Dissector parses packet data into structured data, it is set by
+// WithDissector and run by ReadPacketDissect
+type Dissector interface {
+	Dissect(data []byte) (DissectResult, error)
+}
+
+// EthernetDissector dissects Ethernet II frames, it is a reference
+// Dissector parsing the link layer only
+type EthernetDissector struct{}
+
+// Dissect implements Dissector
+func (EthernetDissector) Dissect(data []byte) (DissectResult, error) {
+	f, err := EthernetDecoder{}.Decode(data)
+	if err != nil {
+		return DissectResult{}, err
+	}
+	return DissectResult{Link: f}, nil
+}
+
+// ReadPacketDissect reads the packet like ReadPacket and runs the dissector
+// set by WithDissector on its data. The result refers to Data of p.
+func (pcap *PCAP) ReadPacketDissect(p *Packet) (DissectResult, error) {
+	d := pcap.opts.dissector
+	if d == nil {
+		return DissectResult{}, errors.New("cannot call ReadPacketDissect, no dissector is set")
+	}
+	if _, err := pcap.ReadPacket(p); err != nil {
+		return DissectResult{}, err
+	}
+	r, err := d.Dissect(p.Data)
+	if err != nil {
+		return DissectResult{}, fmt.Errorf("cannot dissect packet %d: %w", pcap.Len()-1, err)
+	}
+	return r, nil
+}
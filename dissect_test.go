@@ -0,0 +1,52 @@
+package lpcap
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPacketDissect(t *testing.T) {
+	frame := []byte{
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // dst
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x01, // src
+		0x08, 0x06, // ARP
+		0x00, 0x01,
+	}
+	w := NewMemory()
+	for _, data := range [][]byte{frame, frame[:8]} {
+		_, err := w.WritePacket(Packet{PacketType: PacketTypeBroadcast, Len: uint32(len(data)), Data: data})
+		assert.NoError(t, err)
+	}
+
+	pcap, err := OpenMemory(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pcap.ReadPacketDissect(new(Packet))
+	assert.Error(t, err)
+	assert.Equal(t, 0, pcap.Len())
+
+	pcap, err = OpenMemory(w.Bytes(), WithDissector(EthernetDissector{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := new(Packet)
+	r, err := pcap.ReadPacketDissect(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, DissectResult{Link: LinkFrame{
+		Dst:     net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		Src:     net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01},
+		Type:    0x0806,
+		Payload: []byte{0x00, 0x01},
+	}}, r)
+	assert.Equal(t, frame, p.Data)
+
+	// truncated frame
+	_, err = pcap.ReadPacketDissect(p)
+	assert.Error(t, err)
+	assert.Len(t, p.Data, 8)
+}
@@ -4,10 +4,35 @@
 package lpcap
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 )
 
+// ErrUnsupportedOperation is returned by methods that are not supported by
+// the way the PCAP was opened, such as writing to a file opened for reading
+var ErrUnsupportedOperation = errors.New("unsupported operation")
+
+// ErrTooManyPackets is returned by reads beyond the count of packets
+// limited by WithMaxPackets
+var ErrTooManyPackets = errors.New("too many packets")
+
+// ErrTruncatedPacket is returned by ReadPacket if the file ends in the
+// middle of a packet header
+var ErrTruncatedPacket = errors.New("file ends in the middle of a packet header")
+
+// ErrInvalidPayload is returned by reads of packets rejected by the
+// validator set with SetPayloadValidator
+var ErrInvalidPayload = errors.New("invalid payload")
+
+// ErrCountMismatch is returned by VerifyCount if the file does not contain
+// the expected count of packets
+var ErrCountMismatch = errors.New("packet count mismatch")
+
+// ErrStop is returned by the callback of Each to stop the iteration
+// without an error
+var ErrStop = errors.New("stop iteration")
+
 // ParseError represents the position where the error was found
 // and the typical error message.
 type ParseError struct {
@@ -23,6 +48,21 @@ func (e *ParseError) Unwrap() error {
 	return e.Err
 }
 
+// ValidationError represents the index of the packet that
+// failed validation and the reason.
+type ValidationError struct {
+	Index int
+	Err   error
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("packet: %d, err: %s", e.Index, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
 // ErrorCode represents an internal integer code of error insead of string message
 type ErrorCode int
 
@@ -33,6 +73,7 @@ const (
 	ErrInvalidHeader
 	ErrSizeOverflow
 	ErrNoMorePacket
+	ErrCaptureFull
 )
 
 func (e ErrorCode) Error() string {
@@ -49,6 +90,8 @@ func (e ErrorCode) Error() string {
 		return "Size Overflow"
 	case ErrNoMorePacket:
 		return "No More Packets"
+	case ErrCaptureFull:
+		return "Capture Full"
 	}
 	return strconv.Itoa(int(e))
 }
@@ -0,0 +1,64 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+)
+
+// Length of Ethernet II frame header: destination, source and EtherType
+const ethernetHeaderSize = 14
+
+var ethernetBroadcast = net.HardwareAddr{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// EthernetDst returns destination MAC address of the Ethernet II frame
+// stored in the packet data.
+func (p Packet) EthernetDst() (net.HardwareAddr, error) {
+	if len(p.Data) < ethernetHeaderSize {
+		return nil, errors.New("packet data is too short for Ethernet frame")
+	}
+	return net.HardwareAddr(p.Data[:6]), nil
+}
+
+// ClassifyEthernet returns packet type derived from the destination MAC
+// address of the Ethernet II frame, or 0 if the frame is too short.
+// The result can be compared with the stored PacketType.
+func (p Packet) ClassifyEthernet() uint8 {
+	dst, err := p.EthernetDst()
+	if err != nil {
+		return 0
+	}
+	return classifyAddr(dst)
+}
+
+// classifyAddr returns packet type of a frame sent to the IEEE 802 MAC
+// address dst, which is shared by Ethernet and 802.11
+func classifyAddr(dst net.HardwareAddr) uint8 {
+	switch {
+	case bytes.Equal(dst, ethernetBroadcast):
+		return PacketTypeBroadcast
+	case dst[0]&0x01 != 0: // group bit
+		return PacketTypeMulticast
+	}
+	return PacketTypeUnicast
+}
+
+// EthernetDecoder decodes Ethernet II frames, Type is the EtherType
+type EthernetDecoder struct{}
+
+// Decode implements Decoder
+func (EthernetDecoder) Decode(data []byte) (LinkFrame, error) {
+	if len(data) < ethernetHeaderSize {
+		return LinkFrame{}, errors.New("packet data is too short for Ethernet frame")
+	}
+	return LinkFrame{
+		Dst:     net.HardwareAddr(data[:6]),
+		Src:     net.HardwareAddr(data[6:12]),
+		Type:    binary.BigEndian.Uint16(data[12:]),
+		Payload: data[ethernetHeaderSize:],
+	}, nil
+}
@@ -0,0 +1,33 @@
+package lpcap
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEthernetDst(t *testing.T) {
+	frame := []byte{
+		0x01, 0x00, 0x5e, 0x00, 0x00, 0xfb, // dst: mDNS multicast
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x01, // src
+		0x08, 0x00, // IPv4
+		0x45, 0x00,
+	}
+	p := Packet{PacketType: PacketTypeMulticast, Len: uint32(len(frame)), Data: frame}
+
+	dst, err := p.EthernetDst()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, net.HardwareAddr{0x01, 0x00, 0x5e, 0x00, 0x00, 0xfb}, dst)
+	assert.Equal(t, uint8(PacketTypeMulticast), p.ClassifyEthernet())
+
+	copy(frame, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	assert.Equal(t, uint8(PacketTypeBroadcast), p.ClassifyEthernet())
+	copy(frame, []byte{0x02, 0x00, 0x00, 0x00, 0x00, 0x02})
+	assert.Equal(t, uint8(PacketTypeUnicast), p.ClassifyEthernet())
+
+	_, err = Packet{Data: frame[:10]}.EthernetDst()
+	assert.Error(t, err)
+}
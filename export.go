@@ -0,0 +1,98 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// PacketTypeString returns the name of the packet type
+func PacketTypeString(pt uint8) string {
+	switch pt {
+	case PacketTypeBroadcast:
+		return "broadcast"
+	case PacketTypeUnicast:
+		return "unicast"
+	case PacketTypeMulticast:
+		return "multicast"
+	}
+	return strconv.Itoa(int(pt))
+}
+
+// ExportText writes a one line summary of every packet in the file to w:
+// sequence number starting from 1, timestamp, interface index, packet type
+// and length. The timestamp is the low 32 bits of nanoseconds since the
+// capture start, also in files with timestamp deltas. Only packet headers
+// are read and the read offset is not moved.
+func (pcap *PCAP) ExportText(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	seq := 0
+	err := pcap.scan(func(info PacketInfo) error {
+		seq++
+		_, err := fmt.Fprintf(bw, "%6d %10d %3d %-9s %5d\n",
+			seq, info.elapsed, info.Index, PacketTypeString(info.PacketType), info.Len)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// ExportCSV writes a header row and one row of every packet in the file to
+// w, with columns interface index, packet type, timestamp and length, and
+// the data in hexadecimal if includePayloadHex is set. The timestamp is
+// the one of ExportText. Packets are read by a Clone from the first one,
+// so the read offset is not moved. Without the data only packet headers
+// are read.
+func (pcap *PCAP) ExportCSV(w io.Writer, includePayloadHex bool) error {
+	cw := csv.NewWriter(w)
+	header := []string{"index", "type", "timestamp", "len"}
+	if includePayloadHex {
+		header = append(header, "payload")
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	row := func(index uint16, pt uint8, ts, len uint32) []string {
+		return []string{
+			strconv.Itoa(int(index)),
+			PacketTypeString(pt),
+			strconv.FormatUint(uint64(ts), 10),
+			strconv.FormatUint(uint64(len), 10),
+		}
+	}
+
+	var err error
+	if includePayloadHex {
+		var c *PCAP
+		if c, err = pcap.rewound(); err != nil {
+			return err
+		}
+		p := &Packet{Data: []byte{}}
+		for c.Next() && err == nil {
+			if _, err = c.readPacket(p, p.Data); err == nil {
+				ts := p.Timestamp
+				if c.h.flags&FlagTimestampDelta != 0 {
+					ts = uint32(p.AbsoluteTime().UnixNano() - c.h.captureStart)
+				}
+				err = cw.Write(append(row(p.Index, p.PacketType, ts, p.Len), hex.EncodeToString(p.Data)))
+			}
+		}
+	} else {
+		err = pcap.scan(func(info PacketInfo) error {
+			return cw.Write(row(info.Index, info.PacketType, info.elapsed, info.Len))
+		})
+	}
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
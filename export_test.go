@@ -0,0 +1,74 @@
+package lpcap
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportText(t *testing.T) {
+	pcap := NewMemory()
+	packets := []Packet{
+		{Index: 0, PacketType: PacketTypeBroadcast, Timestamp: 1000, Len: 60, Data: make([]byte, 60)},
+		{Index: 2, PacketType: PacketTypeUnicast, Timestamp: 1500, Len: 1514, Data: make([]byte, 1514)},
+		{Index: 12, PacketType: PacketTypeMulticast, Timestamp: 4294967295, Len: 0},
+	}
+	for _, p := range packets {
+		if _, err := pcap.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := pcap.ExportText(&buf); err != nil {
+		t.Fatal(err)
+	}
+	const golden = "" +
+		"     1       1000   0 broadcast    60\n" +
+		"     2       1500   2 unicast    1514\n" +
+		"     3 4294967295  12 multicast     0\n"
+	assert.Equal(t, golden, buf.String())
+}
+
+func TestExportCSV(t *testing.T) {
+	pcap := NewMemory()
+	packets := []Packet{
+		{Index: 3, PacketType: PacketTypeBroadcast, Timestamp: 1000, Len: 2, Data: []byte{0xde, 0xad}},
+		{Index: 300, PacketType: PacketTypeUnicast, Timestamp: 4294967295, Len: 0, Data: []byte{}},
+	}
+	for _, p := range packets {
+		if _, err := pcap.WritePacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := pcap.ExportCSV(&buf, false); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{
+		{"index", "type", "timestamp", "len"},
+		{"3", "broadcast", "1000", "2"},
+		{"300", "unicast", "4294967295", "0"},
+	}, records)
+
+	buf.Reset()
+	if err := pcap.ExportCSV(&buf, true); err != nil {
+		t.Fatal(err)
+	}
+	records, err = csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{
+		{"index", "type", "timestamp", "len", "payload"},
+		{"3", "broadcast", "1000", "2", "dead"},
+		{"300", "unicast", "4294967295", "0", ""},
+	}, records)
+}
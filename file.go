@@ -0,0 +1,324 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+)
+
+// WriteFile creates a capture on the specified path with the given link
+// type and snap length and writes all packets to it. The capture is
+// written like with CreateAtomic, so on failure an existing file on path
+// is left untouched.
+func WriteFile(path string, link LinkType, snapLen uint32, packets []Packet) error {
+	return Capture(path, packets, WithLinkType(link), WithSnapLength(snapLen))
+}
+
+// Capture creates a capture on the specified path with the given options
+// and writes all packets to it. The capture is written like with
+// CreateAtomic, so on failure an existing file on path is left untouched
+// and no partially written file remains.
+func Capture(path string, packets []Packet, opts ...Option) error {
+	pcap, err := CreateAtomic(path, opts...)
+	if err != nil {
+		return err
+	}
+	for _, p := range packets {
+		if _, err := pcap.WritePacket(p); err != nil {
+			return errors.Join(err, pcap.discard())
+		}
+	}
+	return pcap.Close()
+}
+
+// ReadAllFrom opens the capture on the specified path with the given
+// options and reads all of its packets
+func ReadAllFrom(path string, opts ...Option) ([]Packet, error) {
+	pcap, err := Open(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	packets, err := pcap.ReadAll()
+	return packets, errors.Join(err, pcap.Close())
+}
+
+// Upgrade rewrites the capture on srcPath in the current format version
+// to dstPath, keeping the link type, snap length, packet flags, interface
+// names and capture start of the file header. Concatenated captures are
+// merged into one, timestamps of following captures are rebased to the
+// capture start of the first one, and their interface names are merged,
+// failing if two captures name the same interface index differently.
+// dstPath must not be srcPath or a link to it. On failure the partially
+// written file is removed.
+func Upgrade(srcPath, dstPath string) error {
+	// creating dstPath truncates it, which would destroy the source
+	if ds, err := os.Stat(dstPath); err == nil {
+		ss, err := os.Stat(srcPath)
+		if err != nil {
+			return err
+		}
+		if os.SameFile(ss, ds) {
+			return fmt.Errorf("cannot upgrade %s, destination %s is the same file", srcPath, dstPath)
+		}
+	}
+	src, err := Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	interfaces, err := src.mergeInterfaces()
+	if err != nil {
+		return err
+	}
+
+	opts := []Option{WithLinkType(src.h.link), WithSnapLength(src.h.snapLen)}
+	if src.h.flags&FlagPacketFlags != 0 {
+		opts = append(opts, WithPacketFlags())
+	}
+	if src.h.flags&FlagSummaryFooter != 0 {
+		opts = append(opts, WithSummaryFooter())
+	}
+	if src.h.flags&FlagPacketCount != 0 {
+		opts = append(opts, WithPacketCount())
+	}
+	dst, err := Create(dstPath, opts...)
+	if err != nil {
+		return err
+	}
+	fail := func(err error) error {
+		return errors.Join(err, dst.Close(), os.Remove(dstPath))
+	}
+	if start, ok := src.CaptureStart(); ok {
+		if err := dst.SetCaptureStart(start); err != nil {
+			return fail(err)
+		}
+	}
+	for index, name := range interfaces {
+		if err := dst.AddInterface(index, name); err != nil {
+			return fail(err)
+		}
+	}
+
+	p := &Packet{Data: []byte{}}
+	for src.Next() {
+		if _, err := src.readPacket(p, p.Data); err != nil {
+			return fail(err)
+		}
+		// both timestamps wrap around, so the difference of starts is
+		// added modulo 2^32
+		p.Timestamp += uint32(p.start - dst.h.captureStart)
+		if _, err := dst.WritePacket(*p); err != nil {
+			return fail(err)
+		}
+	}
+	if err := dst.Close(); err != nil {
+		return errors.Join(err, os.Remove(dstPath))
+	}
+	return nil
+}
+
+// mergeInterfaces returns the interface names of all concatenated
+// captures in the file. Names of captures without packets are only taken
+// from the first one, since no packet refers to them.
+func (pcap *PCAP) mergeInterfaces() (map[uint16]string, error) {
+	names := make(map[uint16]string, len(pcap.h.interfaces))
+	merge := func(fh *fileHeader) error {
+		for index, name := range fh.interfaces {
+			if old, ok := names[index]; ok && old != name {
+				return fmt.Errorf("cannot merge captures, interface %d is named both %q and %q", index, old, name)
+			}
+			names[index] = name
+		}
+		return nil
+	}
+	fh, err := readFileHeader(pcap.rd, 0, atomic.LoadInt64(&pcap.fsize))
+	if err != nil {
+		return nil, err
+	}
+	if err := merge(fh); err != nil {
+		return nil, err
+	}
+	last := fh
+	err = pcap.scanFrom(int64(fh.size), fh, fh.captureStart, func(_ PacketInfo, fh *fileHeader) error {
+		if fh == last {
+			return nil
+		}
+		last = fh
+		return merge(fh)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// tempFile is a temporary file removed when closed
+type tempFile struct {
+	*os.File
+}
+
+func (f tempFile) Close() error {
+	return errors.Join(f.File.Close(), os.Remove(f.Name()))
+}
+
+// CreateTemp creates a capture in a new temporary file in the directory
+// dir, see os.CreateTemp for dir and pattern. The file is removed when
+// the PCAP is closed.
+func CreateTemp(dir, pattern string, opts ...Option) (*PCAP, error) {
+	f, err := os.CreateTemp(dir, pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	pcap, err := newWriter(tempFile{f}, newOptions(opts))
+	if err != nil {
+		return nil, errors.Join(err, tempFile{f}.Close())
+	}
+	return pcap, nil
+}
+
+// Mode selects how NewFromFile uses the file
+type Mode uint8
+
+const (
+	// ModeCreate writes a new file header, the file must be empty
+	ModeCreate Mode = iota
+	// ModeOpen verifies the existing file header for reading
+	ModeOpen
+)
+
+// callerFile is a file owned by the caller, which is not closed with
+// the PCAP
+type callerFile struct {
+	*os.File
+}
+
+func (f callerFile) Close() error {
+	return nil
+}
+
+// NewFromFile wraps an already opened file, writing a file header in
+// ModeCreate, which requires a regular file to be empty, or verifying
+// the existing one in ModeOpen, which requires f to be seekable. Closing
+// the PCAP flushes written data, but does not close f, which stays owned
+// by the caller.
+func NewFromFile(f *os.File, mode Mode, opts ...Option) (*PCAP, error) {
+	switch mode {
+	case ModeCreate:
+		s, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		// the header would be written over existing data
+		if s.Mode().IsRegular() && s.Size() != 0 {
+			return nil, fmt.Errorf("cannot create capture in %s, file is not empty", f.Name())
+		}
+		return newWriter(callerFile{f}, newOptions(opts))
+	case ModeOpen:
+		s, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return newReader(callerFile{f}, s.Size(), newOptions(opts))
+	}
+	return nil, fmt.Errorf("invalid mode %d", mode)
+}
+
+// atomicFile is a temporary file renamed to path when closed
+type atomicFile struct {
+	*os.File
+	path string
+}
+
+// Close syncs and closes the file, renames it to path and syncs the
+// directory, so the rename survives a crash as well
+func (f atomicFile) Close() error {
+	if err := f.File.Sync(); err != nil {
+		return errors.Join(err, f.File.Close(), os.Remove(f.Name()))
+	}
+	if err := f.File.Close(); err != nil {
+		return errors.Join(err, os.Remove(f.Name()))
+	}
+	if err := os.Rename(f.Name(), f.path); err != nil {
+		return errors.Join(err, os.Remove(f.Name()))
+	}
+	return syncDir(filepath.Dir(f.path))
+}
+
+// syncDir commits the entries of the directory dir to the storage.
+// Directories cannot be synced on Windows, where it does nothing.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	return errors.Join(d.Sync(), d.Close())
+}
+
+// CreateAtomic creates a capture like Create, but the packets are written
+// to a temporary file in the directory of path, which is renamed to path
+// when the PCAP is closed. A capture interrupted by a crash never appears
+// on path half-written, an existing file on path is replaced on Close.
+// Close syncs the file before the rename and the directory after it. If
+// Close fails to complete the capture, the temporary file is removed. The
+// temporary file is created with the mode of WithFileMode and the umask
+// applied, so path gets the same permissions as with Create.
+func CreateAtomic(path string, opts ...Option) (*PCAP, error) {
+	o := newOptions(opts)
+	f, err := createTemp(filepath.Dir(path), "."+filepath.Base(path)+".", o)
+	if err != nil {
+		return nil, err
+	}
+	pcap, err := newWriter(atomicFile{f, path}, o)
+	if err != nil {
+		return nil, errors.Join(err, tempFile{f}.Close())
+	}
+	return pcap, nil
+}
+
+// discard closes a PCAP created by CreateAtomic without renaming the
+// temporary file to path, the file is removed instead
+func (pcap *PCAP) discard() error {
+	if f, ok := pcap.rd.(atomicFile); ok {
+		pcap.rd = tempFile{f.File}
+	}
+	return pcap.Close()
+}
+
+// createTemp creates a new file in dir with a random name starting with
+// prefix like os.CreateTemp, but with the open flags and mode of o
+func createTemp(dir, prefix string, o options) (*os.File, error) {
+	for try := 0; ; try++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		f, err := os.OpenFile(name, o.openFlags()|os.O_EXCL, o.mode)
+		if os.IsExist(err) && try < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
+
+// ReadAll reads all packets from the current offset until the end of
+// the file. Data of every packet is a separate allocation.
+func (pcap *PCAP) ReadAll() ([]Packet, error) {
+	var packets []Packet
+	for pcap.Next() {
+		var p Packet
+		if _, err := pcap.readPacket(&p, []byte{}); err != nil {
+			return packets, err
+		}
+		packets = append(packets, p)
+	}
+	return packets, nil
+}
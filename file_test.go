@@ -0,0 +1,382 @@
+package lpcap
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	packets := []Packet{
+		{Index: 1, PacketType: PacketTypeUnicast, Timestamp: 10, Len: 3, Data: []byte{1, 2, 3}},
+		{Index: 2, PacketType: PacketTypeBroadcast, Timestamp: 20, Len: 1, Data: []byte{4}},
+		{Index: 3, PacketType: PacketTypeMulticast, Timestamp: 30, Len: 0, Data: []byte{}},
+	}
+	if err := WriteFile(path, LinkTypeEthernet80211, 1024, packets); err != nil {
+		t.Fatal(err)
+	}
+
+	pcap, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	assert.Equal(t, LinkTypeEthernet80211, pcap.LinkType())
+	assert.Equal(t, uint32(1024), pcap.h.snapLen)
+
+	got, err := pcap.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, packets, got)
+}
+
+func TestWriteFileRemovesPartial(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	packets := []Packet{
+		{PacketType: PacketTypeUnicast, Len: 3, Data: []byte{1, 2, 3}},
+		{PacketType: PacketTypeUnicast, Len: 64, Data: make([]byte, 64)},
+	}
+	assert.Error(t, WriteFile(path, LinkTypeEthernet2, 32, packets))
+	_, err := os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	// an existing file is kept as it was
+	if err := WriteFile(path, LinkTypeEthernet2, 32, packets[:1]); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, WriteFile(path, LinkTypeEthernet2, 32, packets))
+	after, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+	entries, err := os.ReadDir(filepath.Dir(path))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestCaptureReadAllFrom(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "capture")
+	packets := []Packet{
+		{Index: 1, PacketType: PacketTypeUnicast, Timestamp: 10, Len: 2, Data: []byte{1, 2}, Flags: 0x8001},
+		{Index: 2, PacketType: PacketTypeBroadcast, Timestamp: 20, Len: 0, Data: []byte{}},
+	}
+	if err := Capture(path, packets, WithPacketFlags()); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadAllFrom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, packets, got)
+
+	_, err = ReadAllFrom(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+	assert.Error(t, Capture(filepath.Join(dir, "invalid"), packets, WithSnapLength(0)))
+	assert.Error(t, Capture(path, []Packet{{PacketType: 3}}))
+	// the failed capture does not replace the existing one
+	got, err = ReadAllFrom(path)
+	assert.NoError(t, err)
+	assert.Equal(t, packets, got)
+
+	// truncated file
+	if err := Capture(path, packets); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, os.Truncate(path, createdHeaderSize+4))
+	got, err = ReadAllFrom(path)
+	assert.Error(t, err)
+	assert.Empty(t, got)
+}
+
+func TestCreateTemp(t *testing.T) {
+	dir := t.TempDir()
+	pcap, err := CreateTemp(dir, "*.lpcap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 3, Data: []byte{1, 2, 3}})
+	assert.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, entries, 1)
+
+	assert.NoError(t, pcap.Close())
+	entries, err = os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Empty(t, entries)
+}
+
+func TestCreateAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "atomic.lpcap")
+	pcap, err := CreateAtomic(path, WithFileMode(0640))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{byte(i)}})
+		assert.NoError(t, err)
+	}
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, pcap.Close())
+	s, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, umasked(t, 0640), s.Mode().Perm())
+	packets, err := ReadAllFrom(path)
+	assert.NoError(t, err)
+	assert.Len(t, packets, 3)
+
+	// only the published file is left
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, entries, 1)
+}
+
+func TestCreateAtomicSync(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "atomic.lpcap")
+	pcap, err := CreateAtomic(path, WithSync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{1}})
+	assert.NoError(t, err)
+	f := pcap.rd.(atomicFile).File
+	assert.Equal(t, os.O_SYNC, openFlagsOf(t, f)&os.O_SYNC)
+
+	assert.NoError(t, pcap.Close())
+	packets, err := ReadAllFrom(path)
+	assert.NoError(t, err)
+	assert.Len(t, packets, 1)
+}
+
+func TestNewFromFile(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "fd"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	pcap, err := NewFromFile(f, ModeCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 2, Data: []byte{1, 2}})
+	assert.NoError(t, err)
+	assert.NoError(t, pcap.Close())
+
+	pcap, err = NewFromFile(f, ModeOpen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := pcap.ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, packets, 1) {
+		assert.Equal(t, []byte{1, 2}, packets[0].Data)
+	}
+	assert.NoError(t, pcap.Close())
+	// the file is still open
+	_, err = f.Stat()
+	assert.NoError(t, err)
+
+	// existing data is not overwritten
+	before, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = NewFromFile(f, ModeCreate)
+	assert.Error(t, err)
+	after, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, before, after)
+
+	_, err = NewFromFile(f, Mode(0xff))
+	assert.Error(t, err)
+}
+
+func TestNewFromFilePipe(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	pcap, err := NewFromFile(w, ModeCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{7}})
+	assert.NoError(t, err)
+	assert.NoError(t, pcap.Close())
+	assert.NoError(t, w.Close())
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcap, err = OpenMemory(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := pcap.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 1)
+}
+
+func TestUpgrade(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "v1.0"), filepath.Join(dir, "upgraded")
+
+	// version 1.0 file with two packets
+	b := make([]byte, minFileSize, minFileSize+2*(minPacketSize+2))
+	binary.LittleEndian.PutUint16(b, lpcapmx)
+	binary.LittleEndian.PutUint16(b[2:], MajorVer)
+	binary.LittleEndian.PutUint32(b[6:], 512)
+	binary.LittleEndian.PutUint32(b[10:], uint32(LinkTypeEthernet80211))
+	for i := 0; i < 2; i++ {
+		h := make([]byte, minPacketSize)
+		h[0] = byte(0xf0 + i)
+		h[1] = PacketTypeMulticast
+		binary.LittleEndian.PutUint32(h[2:], uint32(1000*i))
+		binary.LittleEndian.PutUint32(h[6:], 2)
+		b = append(b, h...)
+		b = append(b, byte(i), 0xaa)
+	}
+	if err := os.WriteFile(src, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Upgrade(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	pcap, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	assert.Equal(t, uint16(MinorVer), pcap.h.minorVer)
+	assert.Equal(t, LinkTypeEthernet80211, pcap.LinkType())
+	assert.Equal(t, uint32(512), pcap.h.snapLen)
+	packets, err := pcap.ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, packets, 2) {
+		for i, p := range packets {
+			assert.Equal(t, uint16(0xf0+i), p.Index)
+			assert.Equal(t, uint8(PacketTypeMulticast), p.PacketType)
+			assert.Equal(t, uint32(1000*i), p.Timestamp)
+			assert.Equal(t, []byte{byte(i), 0xaa}, p.Data)
+		}
+	}
+
+	assert.Error(t, Upgrade(filepath.Join(dir, "missing"), filepath.Join(dir, "out")))
+	_, err = os.Stat(filepath.Join(dir, "out"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUpgradeConcatenated(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	var raw []byte
+	for i, offset := range []time.Duration{0, time.Second} {
+		pcap := NewMemory()
+		assert.NoError(t, pcap.SetCaptureStart(start.Add(offset)))
+		assert.NoError(t, pcap.AddInterface(uint16(i), "eth"))
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Timestamp: 10, Len: 1, Data: []byte{byte(i)}})
+		assert.NoError(t, err)
+		raw = append(raw, pcap.Bytes()...)
+	}
+	src, dst := filepath.Join(dir, "concat"), filepath.Join(dir, "merged")
+	if err := os.WriteFile(src, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Upgrade(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	pcap, err := Open(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	packets, err := pcap.ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, packets, 2) {
+		assert.True(t, start.Add(10).Equal(packets[0].AbsoluteTime()))
+		assert.True(t, start.Add(time.Second+10).Equal(packets[1].AbsoluteTime()))
+	}
+	// interface names of both captures are kept
+	for i := uint16(0); i < 2; i++ {
+		name, ok := pcap.InterfaceName(i)
+		assert.True(t, ok)
+		assert.Equal(t, "eth", name)
+	}
+}
+
+func TestUpgradeInterfaceConflict(t *testing.T) {
+	dir := t.TempDir()
+	var raw []byte
+	for _, name := range []string{"eth0", "wlan0"} {
+		pcap := NewMemory()
+		assert.NoError(t, pcap.AddInterface(1, name))
+		_, err := pcap.WritePacket(Packet{Index: 1, PacketType: PacketTypeUnicast, Len: 1, Data: []byte{1}})
+		assert.NoError(t, err)
+		raw = append(raw, pcap.Bytes()...)
+	}
+	src, dst := filepath.Join(dir, "concat"), filepath.Join(dir, "merged")
+	if err := os.WriteFile(src, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Upgrade(src, dst)
+	assert.ErrorContains(t, err, "interface 1")
+	_, err = os.Stat(dst)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestUpgradeSameFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "capture")
+	if err := Capture(path, []Packet{{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{1}}}); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the source is neither truncated nor removed
+	check := func(dst string) {
+		assert.Error(t, Upgrade(path, dst))
+		got, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, raw, got)
+	}
+	check(path)
+	link := filepath.Join(dir, "link")
+	if err := os.Link(path, link); err != nil {
+		t.Skip("hard links are not supported:", err)
+	}
+	check(link)
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"bytes"
+	"iter"
+	"sync/atomic"
+)
+
+// FindPayload returns indices of packets, counted from 0 in file order,
+// whose data contains pattern. Packets are read one by one into a reused
+// buffer by a Clone, so the read offset is not moved.
+func (pcap *PCAP) FindPayload(pattern []byte) ([]int, error) {
+	c, err := pcap.rewound()
+	if err != nil {
+		return nil, err
+	}
+
+	var indices []int
+	p := &Packet{Data: []byte{}}
+	for i := 0; c.Next(); i++ {
+		if _, err := c.readPacket(p, p.Data); err != nil {
+			return indices, err
+		}
+		if bytes.Index(p.Data, pattern) >= 0 {
+			indices = append(indices, i)
+		}
+	}
+	return indices, nil
+}
+
+// rewound returns a Clone of pcap positioned at the first packet
+// of the file, with the file header of the first capture
+func (pcap *PCAP) rewound() (*PCAP, error) {
+	fh, err := readFileHeader(pcap.rd, 0, atomic.LoadInt64(&pcap.fsize))
+	if err != nil {
+		return nil, err
+	}
+	c := pcap.Clone()
+	c.h = fh
+	c.prevTime = fh.captureStart
+	atomic.StoreInt64(&c.offset, int64(fh.size))
+	return c, nil
+}
+
+// Match is a packet found by Find
+type Match struct {
+	// Index of the packet counted from 0 in file order
+	Index int
+	// Packet with Data owned by the caller
+	Packet Packet
+}
+
+// Find returns an iterator over packets whose data contains pattern.
+// Packets are read by a Clone from the first one, so the read offset is
+// not moved. Iteration stops after the first error, which is yielded with
+// the index of the packet that could not be read.
+func (pcap *PCAP) Find(pattern []byte) iter.Seq2[Match, error] {
+	return pcap.find(func(data []byte) bool {
+		return bytes.Contains(data, pattern)
+	})
+}
+
+// FindFold is like Find, but ASCII letters of pattern and of packet data
+// are compared case-insensitively. Other bytes must match exactly.
+func (pcap *PCAP) FindFold(pattern []byte) iter.Seq2[Match, error] {
+	pattern = asciiLower(nil, pattern)
+	var buf []byte
+	return pcap.find(func(data []byte) bool {
+		buf = asciiLower(buf[:0], data)
+		return bytes.Contains(buf, pattern)
+	})
+}
+
+// find returns an iterator over packets whose data is matched by match
+func (pcap *PCAP) find(match func(data []byte) bool) iter.Seq2[Match, error] {
+	return func(yield func(Match, error) bool) {
+		c, err := pcap.rewound()
+		if err != nil {
+			yield(Match{}, err)
+			return
+		}
+		for i := 0; c.Next(); i++ {
+			var p Packet
+			if _, err := c.readPacket(&p, []byte{}); err != nil {
+				yield(Match{Index: i}, err)
+				return
+			}
+			if match(p.Data) && !yield(Match{i, p}, nil) {
+				return
+			}
+		}
+	}
+}
+
+// asciiLower appends b to dst with ASCII upper case letters
+// converted to lower case
+func asciiLower(dst, b []byte) []byte {
+	for _, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		dst = append(dst, c)
+	}
+	return dst
+}
@@ -0,0 +1,111 @@
+package lpcap
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindPayload(t *testing.T) {
+	pcap := NewMemory()
+	payloads := [][]byte{
+		[]byte("GET / HTTP/1.1"),
+		[]byte("\x00\x01\x02"),
+		[]byte("HTTP/1.1 200 OK"),
+		[]byte("HTT"),
+	}
+	for _, data := range payloads {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: uint32(len(data)), Data: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	offset := pcap.offset
+
+	indices, err := pcap.FindPayload([]byte("HTTP/1.1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []int{0, 2}, indices)
+	assert.Equal(t, offset, pcap.offset)
+
+	indices, err = pcap.FindPayload([]byte{0xde, 0xad})
+	assert.NoError(t, err)
+	assert.Empty(t, indices)
+}
+
+func TestFind(t *testing.T) {
+	pcap := NewMemory()
+	payloads := [][]byte{
+		[]byte("marker:A"),
+		[]byte("nothing"),
+		[]byte("xx MARKER xx"),
+		[]byte("\xffmarker"),
+	}
+	for _, data := range payloads {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: uint32(len(data)), Data: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var indices []int
+	for m, err := range pcap.Find([]byte("marker")) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		indices = append(indices, m.Index)
+		assert.Equal(t, payloads[m.Index], m.Packet.Data)
+	}
+	assert.Equal(t, []int{0, 3}, indices)
+
+	indices = nil
+	for m, err := range pcap.FindFold([]byte("Marker")) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		indices = append(indices, m.Index)
+	}
+	assert.Equal(t, []int{0, 2, 3}, indices)
+
+	// stop early
+	for m := range pcap.FindFold([]byte("marker")) {
+		assert.Equal(t, 0, m.Index)
+		break
+	}
+	assert.Equal(t, ErrOk, pcap.LastError())
+}
+
+func TestFindReadError(t *testing.T) {
+	w := NewMemory()
+	for _, data := range [][]byte{[]byte("marker"), []byte("marker")} {
+		_, err := w.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: uint32(len(data)), Data: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := w.Bytes()
+	pcap, err := OpenMemory(b[:len(b)-2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var indices []int
+	var errs []error
+	for m, err := range pcap.Find([]byte("marker")) {
+		indices = append(indices, m.Index)
+		errs = append(errs, err)
+	}
+	assert.Equal(t, []int{0, 1}, indices)
+	if assert.Len(t, errs, 2) {
+		assert.NoError(t, errs[0])
+		assert.ErrorIs(t, errs[1], io.ErrUnexpectedEOF)
+	}
+	// the error is not stored in pcap
+	assert.Equal(t, ErrOk, pcap.LastError())
+}
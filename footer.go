@@ -0,0 +1,156 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// Magic number of the summary footer. Like the file header magic, its high
+// byte lands on the packet type position and is not a valid packet type.
+const footermx = 0x4f46
+
+// Size of the summary footer: magic, first and last timestamps, packet
+// count, offset of the footer in its capture and the checksum of the
+// preceding footer bytes
+const footerSize = 28
+
+// Summary describes packets of the file. With FlagTimestampDelta the
+// timestamps are the low 32 bits of nanoseconds since the capture start
+// rather than the stored deltas.
+type Summary struct {
+	// Timestamp of the first packet
+	FirstTimestamp uint32
+	// Timestamp of the last packet
+	LastTimestamp uint32
+	// Count of packets
+	Count int
+}
+
+// Duration returns the time elapsed between the first and the last packet
+func (s Summary) Duration() time.Duration {
+	return time.Duration(s.LastTimestamp - s.FirstTimestamp)
+}
+
+// add accounts a packet with timestamp ts appended to the file
+func (s *Summary) add(ts uint32) {
+	if s.Count == 0 {
+		s.FirstTimestamp = ts
+	}
+	s.LastTimestamp = ts
+	s.Count++
+}
+
+// marshalFooter returns the footer of summary s stored at offset
+// from the beginning of the capture
+func marshalFooter(s *Summary, offset int64) []byte {
+	b := make([]byte, footerSize)
+	binary.LittleEndian.PutUint16(b, footermx)
+	binary.LittleEndian.PutUint32(b[2:], s.FirstTimestamp)
+	binary.LittleEndian.PutUint32(b[6:], s.LastTimestamp)
+	binary.LittleEndian.PutUint64(b[10:], uint64(s.Count))
+	binary.LittleEndian.PutUint64(b[18:], uint64(offset))
+	binary.LittleEndian.PutUint16(b[26:], headerChecksum(b[:26]))
+	return b
+}
+
+func unmarshalFooter(b []byte) (*Summary, int64, error) {
+	if len(b) < footerSize || !hasFooterMagic(b) {
+		return nil, 0, errors.New("summary footer not found")
+	}
+	if binary.LittleEndian.Uint16(b[26:]) != headerChecksum(b[:26]) {
+		return nil, 0, errors.New("summary footer checksum mismatch")
+	}
+	s := &Summary{
+		FirstTimestamp: binary.LittleEndian.Uint32(b[2:]),
+		LastTimestamp:  binary.LittleEndian.Uint32(b[6:]),
+		Count:          int(binary.LittleEndian.Uint64(b[10:])),
+	}
+	return s, int64(binary.LittleEndian.Uint64(b[18:])), nil
+}
+
+// hasFooterMagic reports whether b starts with the summary footer magic
+func hasFooterMagic(b []byte) bool {
+	return len(b) >= 2 && binary.LittleEndian.Uint16(b) == footermx
+}
+
+// readFooter parses the summary footer at the end of the file and
+// excludes it from the packets, if the file header flags a footer.
+// A missing footer is not an error, the file may not have been closed.
+// The footer of the last of concatenated captures does not describe the
+// whole file, so its summary is not used.
+func (pcap *PCAP) readFooter() {
+	fsize := atomic.LoadInt64(&pcap.fsize)
+	if pcap.h.flags&FlagSummaryFooter == 0 || fsize-footerSize < int64(pcap.h.size) {
+		return
+	}
+	b := make([]byte, footerSize)
+	if _, err := pcap.rd.ReadAt(b, fsize-footerSize); err != nil {
+		return
+	}
+	s, offset, err := unmarshalFooter(b)
+	if err != nil {
+		return
+	}
+	if offset == fsize-footerSize {
+		pcap.footer = s
+	}
+	atomic.StoreInt64(&pcap.fsize, fsize-footerSize)
+}
+
+// writeFooter appends the summary footer after the written packets
+func (pcap *PCAP) writeFooter() error {
+	b := marshalFooter(&pcap.summary, atomic.LoadInt64(&pcap.fsize))
+	var (
+		n   int
+		err error
+	)
+	if pcap.opts.prealloc > 0 {
+		n, err = pcap.rd.(io.WriterAt).WriteAt(b, atomic.LoadInt64(&pcap.fsize))
+	} else {
+		n, err = pcap.rd.Write(b)
+	}
+	if err != nil {
+		pcap.lasterr = ErrWrite
+		return err
+	}
+	atomic.AddInt64(&pcap.fsize, int64(n))
+	return nil
+}
+
+// Summary returns the count and the first and last timestamps of packets
+// in the file. They are taken from the summary footer if the file has one,
+// otherwise headers of all packets are scanned. The read offset is not
+// moved.
+func (pcap *PCAP) Summary() (Summary, error) {
+	if pcap.footer != nil {
+		return *pcap.footer, nil
+	}
+	var s Summary
+	err := pcap.scan(func(info PacketInfo) error {
+		s.add(info.elapsed)
+		return nil
+	})
+	return s, err
+}
+
+// reopenFooter prepares a file opened for appending to rewrite the summary
+// footer on Close, continuing the summary of packets already in the file.
+// The old footer is cut off, so packets are appended in its place.
+func (pcap *PCAP) reopenFooter() error {
+	s, err := pcap.Summary()
+	if err != nil {
+		return err
+	}
+	pcap.summary = s
+	if pcap.footer == nil {
+		return nil
+	}
+	pcap.footer = nil
+	return pcap.truncate()
+}
@@ -0,0 +1,124 @@
+package lpcap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFooterFile(t *testing.T, path string, timestamps ...uint32) {
+	pcap, err := Create(path, WithSummaryFooter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, ts := range timestamps {
+		_, err := pcap.WritePacket(Packet{Index: uint16(i), PacketType: PacketTypeUnicast, Timestamp: ts, Len: 1, Data: []byte{byte(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pcap.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSummaryFooter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "footer")
+	writeFooterFile(t, path, 10, 20, 35)
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, raw, createdHeaderSize+3*(createdPacketSize+1)+footerSize)
+
+	pcap, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	assert.NotNil(t, pcap.footer)
+	s, err := pcap.Summary()
+	assert.NoError(t, err)
+	assert.Equal(t, Summary{FirstTimestamp: 10, LastTimestamp: 35, Count: 3}, s)
+	assert.Equal(t, 25*time.Nanosecond, s.Duration())
+
+	packets, err := pcap.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 3)
+	assert.False(t, pcap.Next())
+}
+
+func TestSummaryScan(t *testing.T) {
+	pcap := NewMemory()
+	for _, ts := range []uint32{5, 7} {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Timestamp: ts, Len: 1, Data: []byte{0}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := pcap.Summary()
+	assert.NoError(t, err)
+	assert.Equal(t, Summary{FirstTimestamp: 5, LastTimestamp: 7, Count: 2}, s)
+}
+
+func TestSummaryFooterAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "footer")
+	writeFooterFile(t, path, 10, 20)
+
+	pcap, err := OpenRW(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Timestamp: 40, Len: 1, Data: []byte{2}})
+	assert.NoError(t, err)
+	assert.NoError(t, pcap.Close())
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	assert.NotNil(t, pcap.footer)
+	s, err := pcap.Summary()
+	assert.NoError(t, err)
+	assert.Equal(t, Summary{FirstTimestamp: 10, LastTimestamp: 40, Count: 3}, s)
+	packets, err := pcap.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 3)
+}
+
+func TestSummaryFooterConcatenated(t *testing.T) {
+	dir := t.TempDir()
+	var raw []byte
+	for _, name := range []string{"first", "second"} {
+		path := filepath.Join(dir, name)
+		writeFooterFile(t, path, 1, 2)
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw = append(raw, b...)
+	}
+
+	pcap, err := OpenMemory(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := pcap.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 4)
+
+	index, err := pcap.BuildIndex()
+	assert.NoError(t, err)
+	assert.Len(t, index, 4)
+
+	// footer of the second capture counts its packets only
+	assert.Nil(t, pcap.footer)
+	s, err := pcap.Summary()
+	assert.NoError(t, err)
+	assert.Equal(t, Summary{FirstTimestamp: 1, LastTimestamp: 2, Count: 4}, s)
+}
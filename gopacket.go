@@ -0,0 +1,95 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// CaptureInfo describes a captured packet. It has the same fields as
+// gopacket.CaptureInfo of github.com/google/gopacket, so the two types can
+// be converted to each other. The package does not depend on gopacket,
+// conversions of gopacket.Packet are provided by the separate module
+// github.com/0x9ef/lpcap/gopacketbridge, see its ToGopacket and WritePacket.
+type CaptureInfo struct {
+	// Time the packet was captured
+	Timestamp time.Time
+	// Length of the captured data
+	CaptureLength int
+	// Original length of the packet
+	Length int
+	// Interface index where frame was received
+	InterfaceIndex int
+	// Unused, for compatibility with gopacket
+	AncillaryData []interface{}
+}
+
+// CaptureInfo returns the capture information of the packet,
+// the timestamp is the AbsoluteTime
+func (p Packet) CaptureInfo() CaptureInfo {
+	return CaptureInfo{
+		Timestamp:      p.AbsoluteTime(),
+		CaptureLength:  len(p.Data),
+		Length:         int(p.Len),
+		InterfaceIndex: int(p.Index),
+	}
+}
+
+// ReadPacketData reads the next packet like ReadPacket, returning its data
+// and capture information. It matches gopacket.PacketDataSource after
+// converting CaptureInfo, so the file can feed a gopacket.PacketSource.
+func (pcap *PCAP) ReadPacketData() ([]byte, CaptureInfo, error) {
+	p := new(Packet)
+	if _, err := pcap.ReadPacketCopy(p); err != nil {
+		return nil, CaptureInfo{}, err
+	}
+	return p.Data, p.CaptureInfo(), nil
+}
+
+// WriteCaptureInfo writes data captured as described by ci, such as a
+// gopacket.Packet converted by CaptureInfo(pkt.Metadata().CaptureInfo).
+// The timestamp is stored relative to the capture start of the file, which
+// is set to the time of the first packet of an empty file without one. A
+// timestamp which does not fit, such as one before the capture start or
+// more than about 4.3 seconds after it, is an error. With timestamp deltas
+// see WritePacketTime. The packet type is derived from the destination
+// address decoded by the decoder registered for the link type of the
+// file, frames which cannot be decoded are written as unicast. Truncated
+// packets are not supported, so Length of ci is ignored.
+func (pcap *PCAP) WriteCaptureInfo(ci CaptureInfo, data []byte) (int, error) {
+	if ci.InterfaceIndex < 0 || ci.InterfaceIndex > math.MaxUint16 {
+		return 0, fmt.Errorf("cannot write packet to PCAP, interface index %d is out of range", ci.InterfaceIndex)
+	}
+	p := Packet{
+		Index: uint16(ci.InterfaceIndex),
+		Len:   uint32(len(data)),
+		Data:  data,
+	}
+	p.PacketType = PacketTypeUnicast
+	if frame, err := p.Decode(pcap.h.link); err == nil && len(frame.Dst) > 0 {
+		p.PacketType = classifyAddr(frame.Dst)
+	}
+	if pcap.h.flags&FlagTimestampDelta != 0 {
+		return pcap.WritePacketTime(p, ci.Timestamp)
+	}
+
+	if pcap.h.flags&FlagCaptureStart == 0 && pcap.writable && pcap.IsEmpty() {
+		if err := pcap.SetCaptureStart(ci.Timestamp); err != nil {
+			return 0, err
+		}
+	}
+	start, ok := pcap.CaptureStart()
+	if !ok {
+		return 0, errors.New("cannot write packet to PCAP, timestamp cannot be stored without capture start")
+	}
+	ts := ci.Timestamp.Sub(start)
+	if ts < 0 || ts > math.MaxUint32 {
+		return 0, fmt.Errorf("cannot write packet to PCAP, timestamp %v from capture start does not fit 32 bits", ts)
+	}
+	p.Timestamp = uint32(ts)
+	return pcap.WritePacket(p)
+}
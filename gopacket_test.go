@@ -0,0 +1,121 @@
+package lpcap
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureInfoRoundTrip(t *testing.T) {
+	frame := []byte{
+		0x01, 0x00, 0x5e, 0x00, 0x00, 0x01, // multicast dst
+		0x02, 0x00, 0x00, 0x00, 0x00, 0x01, // src
+		0x08, 0x00, // IPv4
+		0x45, 0x00,
+	}
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	ci := CaptureInfo{
+		Timestamp:      start.Add(1500 * time.Millisecond),
+		CaptureLength:  len(frame),
+		Length:         len(frame),
+		InterfaceIndex: 300,
+	}
+
+	w := NewMemory()
+	assert.NoError(t, w.SetCaptureStart(start))
+	_, err := w.WriteCaptureInfo(ci, frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.WriteCaptureInfo(CaptureInfo{InterfaceIndex: -1}, frame)
+	assert.Error(t, err)
+
+	pcap, err := OpenMemory(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, got, err := pcap.ReadPacketData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, frame, data)
+	assert.True(t, ci.Timestamp.Equal(got.Timestamp))
+	got.Timestamp = ci.Timestamp
+	assert.Equal(t, ci, got)
+
+	_, _, err = pcap.ReadPacketData()
+	assert.Equal(t, io.EOF, err)
+
+	pcap.SetOffset(pcap.DataOffset())
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint8(PacketTypeMulticast), p.PacketType)
+	assert.Equal(t, uint32(1500*time.Millisecond), p.Timestamp)
+}
+
+// dot11Decoder decodes the receiver address of 802.11 data frames
+type dot11Decoder struct{}
+
+func (dot11Decoder) Decode(data []byte) (LinkFrame, error) {
+	if len(data) < 10 {
+		return LinkFrame{}, io.ErrUnexpectedEOF
+	}
+	return LinkFrame{Dst: data[4:10]}, nil
+}
+
+func TestWriteCaptureInfoLinkType(t *testing.T) {
+	// Ethernet multicast destination, 802.11 broadcast receiver
+	frame := []byte{0x01, 0x00, 0x5e, 0x00, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00, 0x00, 0x00}
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	write := func(lt LinkType) uint8 {
+		w := NewMemory(WithLinkType(lt))
+		if _, err := w.WriteCaptureInfo(CaptureInfo{Timestamp: start}, frame); err != nil {
+			t.Fatal(err)
+		}
+		rd, err := OpenMemory(w.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := new(Packet)
+		if _, err := rd.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		return p.PacketType
+	}
+	assert.Equal(t, uint8(PacketTypeMulticast), write(LinkTypeEthernet2))
+	// without a decoder 802.11 frames are not classified as Ethernet
+	assert.Equal(t, uint8(PacketTypeUnicast), write(LinkTypeEthernet80211))
+	RegisterDecoder(LinkTypeEthernet80211, dot11Decoder{})
+	defer RegisterDecoder(LinkTypeEthernet80211, nil)
+	assert.Equal(t, uint8(PacketTypeBroadcast), write(LinkTypeEthernet80211))
+}
+
+func TestWriteCaptureInfoTimestamp(t *testing.T) {
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	frame := make([]byte, ethernetHeaderSize)
+	w := NewMemory()
+	_, err := w.WriteCaptureInfo(CaptureInfo{Timestamp: start}, frame)
+	assert.NoError(t, err)
+	// the capture start is taken from the first packet
+	got, ok := w.CaptureStart()
+	assert.True(t, ok)
+	assert.True(t, start.Equal(got))
+
+	_, err = w.WriteCaptureInfo(CaptureInfo{Timestamp: start.Add(4 * time.Second)}, frame)
+	assert.NoError(t, err)
+	_, err = w.WriteCaptureInfo(CaptureInfo{Timestamp: start.Add(5 * time.Second)}, frame)
+	assert.Error(t, err)
+	_, err = w.WriteCaptureInfo(CaptureInfo{Timestamp: start.Add(-time.Nanosecond)}, frame)
+	assert.Error(t, err)
+
+	// packets written without a capture start leave no reference
+	w = NewMemory()
+	_, err = w.WritePacket(Packet{PacketType: PacketTypeUnicast})
+	assert.NoError(t, err)
+	_, err = w.WriteCaptureInfo(CaptureInfo{Timestamp: start}, frame)
+	assert.Error(t, err)
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+// Package gopacketbridge converts packets between lpcap and
+// github.com/google/gopacket. It is a separate module, so lpcap itself
+// does not depend on gopacket.
+package gopacketbridge
+
+import (
+	"github.com/0x9ef/lpcap"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Decoder returns the gopacket decoder of the first layer of frames with
+// the link type, frames of unknown link types are decoded as payload
+func Decoder(lt lpcap.LinkType) gopacket.Decoder {
+	switch lt {
+	case lpcap.LinkTypeEthernet2:
+		return layers.LayerTypeEthernet
+	case lpcap.LinkTypeEthernet80211:
+		return layers.LayerTypeDot11
+	}
+	return gopacket.DecodePayload
+}
+
+// ToGopacket decodes the data of p as a frame of the link type lt, such
+// as the LinkType of the file p was read from. The metadata of the
+// returned packet holds the CaptureInfo of p, with the absolute time of
+// the packet. Data is copied, so p may be reused afterwards.
+func ToGopacket(p lpcap.Packet, lt lpcap.LinkType) gopacket.Packet {
+	pkt := gopacket.NewPacket(p.Data, Decoder(lt), gopacket.Default)
+	pkt.Metadata().CaptureInfo = gopacket.CaptureInfo(p.CaptureInfo())
+	pkt.Metadata().Truncated = len(p.Data) < int(p.Len)
+	return pkt
+}
+
+// WritePacket writes the data of pkt to pcap, timestamped and classified
+// by PCAP.WriteCaptureInfo from the capture information of its metadata
+func WritePacket(pcap *lpcap.PCAP, pkt gopacket.Packet) (int, error) {
+	return pcap.WriteCaptureInfo(lpcap.CaptureInfo(pkt.Metadata().CaptureInfo), pkt.Data())
+}
+
+// dataSource adapts PCAP.ReadPacketData to gopacket.PacketDataSource
+type dataSource struct {
+	pcap *lpcap.PCAP
+}
+
+func (s dataSource) ReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	data, ci, err := s.pcap.ReadPacketData()
+	return data, gopacket.CaptureInfo(ci), err
+}
+
+// NewPacketSource returns a gopacket.PacketSource reading the packets of
+// pcap from its current offset, decoded according to its link type
+func NewPacketSource(pcap *lpcap.PCAP) *gopacket.PacketSource {
+	return gopacket.NewPacketSource(dataSource{pcap}, Decoder(pcap.LinkType()))
+}
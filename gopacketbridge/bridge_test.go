@@ -0,0 +1,119 @@
+package gopacketbridge
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/0x9ef/lpcap"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+)
+
+func ethernetFrame(t *testing.T, dst net.HardwareAddr, payload []byte) []byte {
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true},
+		&layers.Ethernet{
+			SrcMAC:       net.HardwareAddr{0x02, 0, 0, 0, 0, 1},
+			DstMAC:       dst,
+			EthernetType: layers.EthernetTypeLLC,
+		},
+		gopacket.Payload(payload),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestEthernetRoundTrip(t *testing.T) {
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	dsts := []net.HardwareAddr{
+		{0x02, 0, 0, 0, 0, 2},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+	}
+	w := lpcap.NewMemory()
+	var sent []gopacket.Packet
+	for i, dst := range dsts {
+		pkt := gopacket.NewPacket(ethernetFrame(t, dst, []byte{byte(i), 1, 2, 3}), layers.LayerTypeEthernet, gopacket.Default)
+		pkt.Metadata().CaptureInfo = gopacket.CaptureInfo{
+			Timestamp:      start.Add(time.Duration(i) * time.Millisecond),
+			CaptureLength:  len(pkt.Data()),
+			Length:         len(pkt.Data()),
+			InterfaceIndex: 3,
+		}
+		if _, err := WritePacket(w, pkt); err != nil {
+			t.Fatal(err)
+		}
+		sent = append(sent, pkt)
+	}
+
+	rd, err := lpcap.OpenMemory(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := new(lpcap.Packet)
+	for i, want := range sent {
+		if _, err := rd.ReadPacketCopy(p); err != nil {
+			t.Fatal(err)
+		}
+		pkt := ToGopacket(*p, rd.LinkType())
+		assert.Equal(t, want.Data(), pkt.Data())
+		ci := pkt.Metadata().CaptureInfo
+		assert.True(t, want.Metadata().Timestamp.Equal(ci.Timestamp))
+		assert.Equal(t, want.Metadata().CaptureLength, ci.CaptureLength)
+		assert.Equal(t, 3, ci.InterfaceIndex)
+
+		eth, ok := pkt.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+		if assert.True(t, ok) {
+			assert.Equal(t, dsts[i], eth.DstMAC)
+		}
+	}
+	rd.Close()
+
+	// the packet type is derived from the destination address
+	rd, err = lpcap.OpenMemory(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []uint8
+	for rd.Next() {
+		if _, err := rd.ReadPacketCopy(p); err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, p.PacketType)
+	}
+	assert.Equal(t, []uint8{lpcap.PacketTypeUnicast, lpcap.PacketTypeBroadcast}, types)
+}
+
+func TestPacketSource(t *testing.T) {
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	w := lpcap.NewMemory()
+	frame := ethernetFrame(t, net.HardwareAddr{0x02, 0, 0, 0, 0, 2}, []byte{1, 2, 3})
+	for i := 0; i < 3; i++ {
+		ci := gopacket.CaptureInfo{Timestamp: start.Add(time.Duration(i)), CaptureLength: len(frame), Length: len(frame)}
+		if _, err := w.WriteCaptureInfo(lpcap.CaptureInfo(ci), frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rd, err := lpcap.OpenMemory(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for pkt := range NewPacketSource(rd).Packets() {
+		assert.NotNil(t, pkt.Layer(layers.LayerTypeEthernet))
+		assert.Equal(t, frame, pkt.Data())
+		n++
+	}
+	assert.Equal(t, 3, n)
+}
+
+func TestDecoder(t *testing.T) {
+	assert.Equal(t, gopacket.Decoder(layers.LayerTypeEthernet), Decoder(lpcap.LinkTypeEthernet2))
+	assert.Equal(t, gopacket.Decoder(layers.LayerTypeDot11), Decoder(lpcap.LinkTypeEthernet80211))
+	pkt := ToGopacket(lpcap.Packet{Len: 2, Data: []byte{1, 2}}, lpcap.LinkTypeFDDI)
+	assert.NotNil(t, pkt.ApplicationLayer())
+	assert.False(t, pkt.Metadata().Truncated)
+}
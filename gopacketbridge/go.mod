@@ -0,0 +1,17 @@
+module github.com/0x9ef/lpcap/gopacketbridge
+
+go 1.23
+
+require (
+	github.com/0x9ef/lpcap v0.0.0
+	github.com/google/gopacket v1.1.19
+	github.com/stretchr/testify v1.8.4
+)
+
+replace github.com/0x9ef/lpcap => ../
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
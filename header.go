@@ -6,24 +6,158 @@ package lpcap
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"io"
+	"sort"
 )
 
 const lpcapmx = 0x4f3e
+
+// Magic number as read from a file written in big-endian byte order
+const lpcapmxSwapped = 0x3e4f
 const minFileSize = 14
 const minPacketSize = 10
 
+// Size of the file header since version 1.1, which appends
+// flags and the total header length to the 14 bytes of version 1.0
+const extFileSize = 18
+
+// Size of the optional packet flags field
+const packetFlagsSize = 2
+
+// Minor version which widened the interface index to 16 bits,
+// storing its high byte after the packet length
+const wideIndexMinorVer = 2
+
+// Size of the interface index high byte stored since version 1.2
+const wideIndexSize = 1
+
+// Size of the header checksum stored in the last bytes of the file header
+const headerChecksumSize = 2
+
+// File header flags, available since version 1.1
+const (
+	// Each packet header carries a 16-bit user flags field
+	FlagPacketFlags uint16 = 1 << iota
+
+	// File header contains a table of interface names
+	FlagInterfaceNames
+
+	// File header ends with a checksum of all preceding header bytes
+	FlagHeaderChecksum
+
+	// File header contains the capture start time, packet timestamps
+	// are relative to it
+	FlagCaptureStart
+
+	// File ends with a summary footer written on Close
+	FlagSummaryFooter
+
+	// File header contains the packet count, rewritten on Close
+	FlagPacketCount
+
+	// Packet timestamps are signed 32-bit deltas from the previous packet,
+	// the first one from the capture start
+	FlagTimestampDelta
+)
+
+// Size of the capture start extension
+const captureStartSize = 8
+
+// Size of the packet count extension: the count and the offset of the
+// end of packets at the time the count was stored
+const packetCountSize = 16
+
 type fileHeader struct {
 	mx       uint16 // magic number
 	majorVer uint16
 	minorVer uint16
 	snapLen  uint32
 	link     LinkType
+	flags    uint16 // since 1.1
+	size     uint16 // total header length and offset of the first packet
+
+	// Header extensions, each present if the corresponding flag is set
+	interfaces   map[uint16]string
+	captureStart int64 // nanoseconds since 1970-01-01 00:00:00 UTC
+	packetCount  int64
+	countEnd     int64 // end of packets when packetCount was stored
+}
+
+// extSize returns the length of header extensions enabled by flags
+func (h *fileHeader) extSize() int {
+	size := 0
+	if h.flags&FlagInterfaceNames != 0 {
+		size += h.interfaceEntrySize() - 1
+		for _, name := range h.interfaces {
+			size += h.interfaceEntrySize() + len(name)
+		}
+	}
+	if h.flags&FlagCaptureStart != 0 {
+		size += captureStartSize
+	}
+	if h.flags&FlagPacketCount != 0 {
+		size += packetCountSize
+	}
+	if h.flags&FlagHeaderChecksum != 0 {
+		size += headerChecksumSize
+	}
+	return size
+}
+
+// headerChecksum computes the ones' complement sum of 16-bit words of b,
+// the same way as the Internet checksum, which detects any single bit error.
+func headerChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(binary.LittleEndian.Uint16(b[i:]))
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1])
+	}
+	for sum > 0xffff {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// hasWideIndex reports whether packets store 16-bit interface index
+func (h *fileHeader) hasWideIndex() bool {
+	return h.minorVer >= wideIndexMinorVer
+}
+
+// packetHeaderSize returns the length of packet header
+// according to the version and flags of the file
+func (h *fileHeader) packetHeaderSize() int {
+	size := minPacketSize
+	if h.hasWideIndex() {
+		size += wideIndexSize
+	}
+	if h.flags&FlagPacketFlags != 0 {
+		size += packetFlagsSize
+	}
+	return size
+}
+
+// interfaceEntrySize returns the length of interface table entry
+// without the name, and of the entry count
+func (h *fileHeader) interfaceEntrySize() int {
+	if h.hasWideIndex() {
+		return 3
+	}
+	return 2
 }
 
 func unmarshalFileHeader(b []byte) (*fileHeader, int64, error) {
 	erroffset := int64(0)
+	if len(b) < minFileSize {
+		return nil, erroffset, errors.New("cannot parse PCAP file, file header is too short")
+	}
 	h := &fileHeader{}
 	mx := binary.LittleEndian.Uint16(b)
+	if mx == lpcapmxSwapped {
+		return nil, erroffset, errors.New("cannot parse PCAP file, file appears to be big-endian, only little-endian byte order is supported")
+	}
 	if mx != lpcapmx {
 		return nil, erroffset, errors.New("cannot parse PCAP file, invalid magix number")
 	}
@@ -31,36 +165,211 @@ func unmarshalFileHeader(b []byte) (*fileHeader, int64, error) {
 	h.majorVer = binary.LittleEndian.Uint16(b[2:])
 	h.minorVer = binary.LittleEndian.Uint16(b[4:])
 	h.snapLen = binary.LittleEndian.Uint32(b[6:])
+	if h.snapLen < minPacketSize {
+		erroffset += 6
+		return nil, erroffset, fmt.Errorf("cannot parse PCAP file, snap length %d is less than packet header length %d", h.snapLen, minPacketSize)
+	}
 	linkType := LinkType(binary.LittleEndian.Uint32(b[10:]))
-	if linkType != LinkTypeEthernet2 && linkType != LinkTypeEthernet80211 {
+	if !linkType.supported() {
 		erroffset += 10
 		return nil, erroffset, errors.New("cannot parse PCAP file, link type is undefined")
 	}
+	h.link = linkType
+	h.size = minFileSize
+	if h.minorVer == 0 {
+		return h, 0, nil
+	}
+
+	if len(b) < extFileSize {
+		erroffset += minFileSize
+		return nil, erroffset, errors.New("cannot parse PCAP file, file header is too short")
+	}
+	h.flags = binary.LittleEndian.Uint16(b[14:])
+	h.size = binary.LittleEndian.Uint16(b[16:])
+	if h.size < extFileSize {
+		erroffset += 16
+		return nil, erroffset, errors.New("cannot parse PCAP file, invalid header length")
+	}
+	if len(b) < int(h.size) {
+		// extensions are parsed once the whole header is read
+		return h, 0, nil
+	}
+
+	if h.flags&FlagHeaderChecksum != 0 {
+		end := int(h.size) - headerChecksumSize
+		if end < extFileSize || binary.LittleEndian.Uint16(b[end:]) != headerChecksum(b[:end]) {
+			erroffset += int64(end)
+			return nil, erroffset, errors.New("cannot parse PCAP file, header checksum mismatch")
+		}
+	}
+	off, err := unmarshalExtensions(b[extFileSize:h.size], h)
+	if err != nil {
+		return nil, extFileSize + off, err
+	}
 	return h, 0, nil
 }
 
+// unmarshalExtensions parses header extensions enabled by flags of h,
+// the extensions are stored in the order of their flag bits, except for
+// the checksum, which is always the last one.
+func unmarshalExtensions(b []byte, h *fileHeader) (int64, error) {
+	off := 0
+	if h.flags&FlagInterfaceNames != 0 {
+		// count and indexes are 8-bit before wide interface indexes
+		wide := h.hasWideIndex()
+		entrySize := h.interfaceEntrySize()
+		if len(b) < off+entrySize-1 {
+			return int64(off), errors.New("cannot parse PCAP file, interface table is truncated")
+		}
+		count := int(b[off])
+		if wide {
+			count = int(binary.LittleEndian.Uint16(b[off:]))
+		}
+		off += entrySize - 1
+		h.interfaces = make(map[uint16]string, count)
+		for i := 0; i < count; i++ {
+			if len(b) < off+entrySize || len(b) < off+entrySize+int(b[off+entrySize-1]) {
+				return int64(off), errors.New("cannot parse PCAP file, interface table is truncated")
+			}
+			index := uint16(b[off])
+			if wide {
+				index = binary.LittleEndian.Uint16(b[off:])
+			}
+			n := int(b[off+entrySize-1])
+			h.interfaces[index] = string(b[off+entrySize : off+entrySize+n])
+			off += entrySize + n
+		}
+	}
+	if h.flags&FlagCaptureStart != 0 {
+		if len(b) < off+captureStartSize {
+			return int64(off), errors.New("cannot parse PCAP file, capture start is truncated")
+		}
+		h.captureStart = int64(binary.LittleEndian.Uint64(b[off:]))
+		off += captureStartSize
+	}
+	if h.flags&FlagPacketCount != 0 {
+		if len(b) < off+packetCountSize {
+			return int64(off), errors.New("cannot parse PCAP file, packet count is truncated")
+		}
+		h.packetCount = int64(binary.LittleEndian.Uint64(b[off:]))
+		h.countEnd = int64(binary.LittleEndian.Uint64(b[off+8:]))
+		off += packetCountSize
+	}
+	return 0, nil
+}
+
+func marshalFileHeader(h *fileHeader) []byte {
+	b := make([]byte, h.size)
+	binary.LittleEndian.PutUint16(b, h.mx)
+	binary.LittleEndian.PutUint16(b[2:], h.majorVer)
+	binary.LittleEndian.PutUint16(b[4:], h.minorVer)
+	binary.LittleEndian.PutUint32(b[6:], h.snapLen)
+	binary.LittleEndian.PutUint32(b[10:], uint32(h.link))
+	if h.minorVer == 0 {
+		return b
+	}
+	binary.LittleEndian.PutUint16(b[14:], h.flags)
+	binary.LittleEndian.PutUint16(b[16:], h.size)
+
+	off := extFileSize
+	if h.flags&FlagInterfaceNames != 0 {
+		wide := h.hasWideIndex()
+		entrySize := h.interfaceEntrySize()
+		indexes := make([]int, 0, len(h.interfaces))
+		for index := range h.interfaces {
+			indexes = append(indexes, int(index))
+		}
+		sort.Ints(indexes)
+		if wide {
+			binary.LittleEndian.PutUint16(b[off:], uint16(len(indexes)))
+		} else {
+			b[off] = uint8(len(indexes))
+		}
+		off += entrySize - 1
+		for _, index := range indexes {
+			name := h.interfaces[uint16(index)]
+			if wide {
+				binary.LittleEndian.PutUint16(b[off:], uint16(index))
+			} else {
+				b[off] = uint8(index)
+			}
+			b[off+entrySize-1] = uint8(len(name))
+			off += entrySize + copy(b[off+entrySize:], name)
+		}
+	}
+	if h.flags&FlagCaptureStart != 0 {
+		binary.LittleEndian.PutUint64(b[off:], uint64(h.captureStart))
+		off += captureStartSize
+	}
+	if h.flags&FlagPacketCount != 0 {
+		binary.LittleEndian.PutUint64(b[off:], uint64(h.packetCount))
+		binary.LittleEndian.PutUint64(b[off+8:], uint64(h.countEnd))
+		off += packetCountSize
+	}
+	if h.flags&FlagHeaderChecksum != 0 {
+		end := len(b) - headerChecksumSize
+		binary.LittleEndian.PutUint16(b[end:], headerChecksum(b[:end]))
+	}
+	return b
+}
+
+// readFileHeader reads and parses the file header located at offset,
+// the header must fit into the first size bytes of the file.
+func readFileHeader(r io.ReaderAt, offset, size int64) (*fileHeader, error) {
+	b := make([]byte, extFileSize)
+	if size-offset < extFileSize {
+		b = b[:size-offset]
+	}
+	if _, err := r.ReadAt(b, offset); err != nil {
+		return nil, err
+	}
+
+	header, erroffset, err := unmarshalFileHeader(b)
+	if err != nil {
+		return nil, &ParseError{Offset: offset + erroffset, Err: err}
+	}
+	if offset+int64(header.size) > size {
+		return nil, &ParseError{Offset: offset + 16, Err: errors.New("cannot parse PCAP file, header length overflows file")}
+	}
+	if int(header.size) <= len(b) {
+		return header, nil
+	}
+
+	// read the whole header with extensions
+	b = make([]byte, header.size)
+	if _, err := r.ReadAt(b, offset); err != nil {
+		return nil, err
+	}
+	header, erroffset, err = unmarshalFileHeader(b)
+	if err != nil {
+		return nil, &ParseError{Offset: offset + erroffset, Err: err}
+	}
+	return header, nil
+}
+
 type packetHeader struct {
-	ifindex   uint8
+	ifindex   uint16
 	ptype     uint8
 	timestamp uint32
 	len       uint32
+	flags     uint16
 	p         []byte
 }
 
-func unmarshalPacketHeader(b []byte, maxLen uint32) (*packetHeader, int64, error) {
+func unmarshalPacketHeader(b []byte, fh *fileHeader) (*packetHeader, int64, error) {
 	erroffset := int64(0)
+	if len(b) < fh.packetHeaderSize() {
+		return nil, erroffset, errors.New("packet header is too short")
+	}
 	h := &packetHeader{}
-	i, pt := b[0], b[1]
-	if pt != PacketTypeBroadcast && pt != PacketTypeUnicast && pt != PacketTypeMulticast {
+	i, pt := uint16(b[0]), b[1]
+	if !isValidPacketType(pt) {
+		erroffset += 1
 		return nil, erroffset, errors.New("undefined packet type")
 	}
 	t := binary.LittleEndian.Uint32(b[2:])
-	if t == 0 {
-		erroffset += 2
-		return nil, erroffset, errors.New("invalid timestamp value")
-	}
 	len := binary.LittleEndian.Uint32(b[6:])
-	if len > maxLen {
+	if len > fh.snapLen {
 		erroffset += 6
 		return nil, erroffset, errors.New("snap length of packet is overflow")
 	}
@@ -68,5 +377,44 @@ func unmarshalPacketHeader(b []byte, maxLen uint32) (*packetHeader, int64, error
 	h.ptype = pt
 	h.timestamp = t
 	h.len = len
+	off := minPacketSize
+	if fh.hasWideIndex() {
+		h.ifindex |= uint16(b[off]) << 8
+		off += wideIndexSize
+	}
+	if fh.flags&FlagPacketFlags != 0 {
+		h.flags = binary.LittleEndian.Uint16(b[off:])
+	}
 	return h, 0, nil
 }
+
+// isValidPacketType reports whether pt is exactly one of packet types
+func isValidPacketType(pt uint8) bool {
+	return pt == PacketTypeBroadcast || pt == PacketTypeUnicast || pt == PacketTypeMulticast
+}
+
+// marshalPacketHeader writes the header of p into b, which must be
+// at least packetHeaderSize bytes long, and returns the written length.
+func marshalPacketHeader(b []byte, p *Packet, fh *fileHeader) int {
+	b[0] = uint8(p.Index)
+	b[1] = p.PacketType
+	binary.LittleEndian.PutUint32(b[2:], p.Timestamp)
+	binary.LittleEndian.PutUint32(b[6:], p.Len)
+	off := minPacketSize
+	if fh.hasWideIndex() {
+		b[off] = uint8(p.Index >> 8)
+		off += wideIndexSize
+	}
+	if fh.flags&FlagPacketFlags != 0 {
+		binary.LittleEndian.PutUint16(b[off:], p.Flags)
+	}
+	return fh.packetHeaderSize()
+}
+
+// hasMagic reports whether b starts with the file header magic number.
+// The high byte of lpcapmx lands on the packet type position and is not a
+// valid packet type, so a file header found at a packet boundary can never
+// be confused with a packet header.
+func hasMagic(b []byte) bool {
+	return len(b) >= 2 && binary.LittleEndian.Uint16(b) == lpcapmx
+}
@@ -0,0 +1,228 @@
+package lpcap
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalPacketHeaderShort(t *testing.T) {
+	assert.NotPanics(t, func() {
+		h, _, err := unmarshalPacketHeader(make([]byte, 5), &fileHeader{snapLen: MaxSnapLength})
+		assert.Nil(t, h)
+		assert.Error(t, err)
+	})
+
+	wide := &fileHeader{minorVer: MinorVer, snapLen: MaxSnapLength, flags: FlagPacketFlags}
+	for _, n := range []int{0, 3, minPacketSize, wide.packetHeaderSize() - 1} {
+		b := []byte{0, PacketTypeUnicast, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}[:n]
+		assert.NotPanics(t, func() {
+			h, off, err := unmarshalPacketHeader(b, wide)
+			assert.Nil(t, h)
+			assert.Equal(t, int64(0), off)
+			assert.Error(t, err)
+		}, "length %d", n)
+	}
+}
+
+func TestUnmarshalFileHeaderShort(t *testing.T) {
+	b := marshalFileHeader(&fileHeader{
+		mx:       lpcapmx,
+		majorVer: MajorVer,
+		minorVer: MinorVer,
+		snapLen:  MaxSnapLength,
+		link:     LinkTypeEthernet2,
+		size:     extFileSize,
+	})
+	for _, n := range []int{0, 5, minFileSize - 1, extFileSize - 1} {
+		assert.NotPanics(t, func() {
+			h, _, err := unmarshalFileHeader(b[:n])
+			assert.Nil(t, h)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "too short")
+			}
+		}, "length %d", n)
+	}
+
+	_, err := OpenMemory(b[:5])
+	assert.Error(t, err)
+}
+
+func TestUnmarshalFileHeaderBigEndian(t *testing.T) {
+	b := make([]byte, extFileSize)
+	binary.BigEndian.PutUint16(b, lpcapmx)
+	binary.BigEndian.PutUint16(b[2:], MajorVer)
+	binary.BigEndian.PutUint16(b[4:], MinorVer)
+	binary.BigEndian.PutUint32(b[6:], MaxSnapLength)
+	binary.BigEndian.PutUint32(b[10:], uint32(LinkTypeEthernet2))
+
+	_, _, err := unmarshalFileHeader(b)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "big-endian")
+	}
+}
+
+func TestUnmarshalFileHeaderSnapLength(t *testing.T) {
+	for _, snapLen := range []uint32{0, minPacketSize - 1} {
+		b := make([]byte, minFileSize)
+		binary.LittleEndian.PutUint16(b, lpcapmx)
+		binary.LittleEndian.PutUint16(b[2:], MajorVer)
+		binary.LittleEndian.PutUint32(b[6:], snapLen)
+		binary.LittleEndian.PutUint32(b[10:], uint32(LinkTypeEthernet2))
+
+		_, off, err := unmarshalFileHeader(b)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "snap length")
+		}
+		assert.Equal(t, int64(6), off)
+	}
+}
+
+func TestUnmarshalPacketHeaderType(t *testing.T) {
+	fh := &fileHeader{snapLen: MaxSnapLength}
+	b := make([]byte, minPacketSize)
+	for _, pt := range []uint8{PacketTypeBroadcast, PacketTypeUnicast, PacketTypeMulticast} {
+		b[1] = pt
+		h, _, err := unmarshalPacketHeader(b, fh)
+		if assert.NoError(t, err) {
+			assert.Equal(t, pt, h.ptype)
+		}
+	}
+
+	combined := []uint8{
+		0,
+		PacketTypeBroadcast | PacketTypeUnicast,
+		PacketTypeUnicast | PacketTypeMulticast,
+		PacketTypeBroadcast | PacketTypeUnicast | PacketTypeMulticast,
+	}
+	for _, pt := range combined {
+		b[1] = pt
+		_, erroffset, err := unmarshalPacketHeader(b, fh)
+		assert.Error(t, err)
+		assert.Equal(t, int64(1), erroffset)
+	}
+}
+
+func TestHeaderChecksum(t *testing.T) {
+	pcap := NewMemory()
+	raw := append([]byte(nil), pcap.Bytes()...)
+
+	_, _, err := unmarshalFileHeader(raw)
+	assert.NoError(t, err)
+
+	// snap length stays valid, but no longer matches the checksum
+	raw[7] ^= 0x01
+	_, erroffset, err := unmarshalFileHeader(raw)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "checksum")
+	}
+	assert.Equal(t, int64(extFileSize), erroffset)
+
+	_, err = OpenMemory(raw)
+	assert.Error(t, err)
+}
+
+func TestWideIndex(t *testing.T) {
+	pcap := NewMemory()
+	assert.NoError(t, pcap.AddInterface(300, "eth300"))
+	_, err := pcap.WritePacket(Packet{
+		Index:      300,
+		PacketType: PacketTypeUnicast,
+		Timestamp:  1,
+		Len:        3,
+		Data:       []byte{1, 2, 3},
+	})
+	assert.NoError(t, err)
+
+	rd, err := OpenMemory(pcap.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := new(Packet)
+	if _, err := rd.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint16(300), p.Index)
+	assert.Equal(t, []byte{1, 2, 3}, p.Data)
+	name, ok := rd.InterfaceName(300)
+	assert.True(t, ok)
+	assert.Equal(t, "eth300", name)
+}
+
+func TestNarrowIndex(t *testing.T) {
+	// version 1.1 file, packets store 8-bit interface index
+	b := make([]byte, extFileSize+minPacketSize+2)
+	binary.LittleEndian.PutUint16(b, lpcapmx)
+	binary.LittleEndian.PutUint16(b[2:], MajorVer)
+	binary.LittleEndian.PutUint16(b[4:], 1)
+	binary.LittleEndian.PutUint32(b[6:], MaxSnapLength)
+	binary.LittleEndian.PutUint32(b[10:], uint32(LinkTypeEthernet2))
+	binary.LittleEndian.PutUint16(b[16:], extFileSize)
+	ph := b[extFileSize:]
+	ph[0] = 200
+	ph[1] = PacketTypeBroadcast
+	binary.LittleEndian.PutUint32(ph[6:], 2)
+	ph[minPacketSize], ph[minPacketSize+1] = 0xaa, 0xbb
+
+	pcap, err := OpenMemory(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint16(200), p.Index)
+	assert.Equal(t, []byte{0xaa, 0xbb}, p.Data)
+	assert.False(t, pcap.Next())
+
+	err = pcap.ValidateWrite([]Packet{{Index: 256, PacketType: PacketTypeUnicast}})
+	assert.Error(t, err)
+}
+
+// fuzzSeeds returns valid captures of every header extension
+func fuzzSeeds(tb testing.TB) [][]byte {
+	var seeds [][]byte
+	for _, opts := range [][]Option{
+		nil,
+		{WithPacketFlags()},
+		{WithSummaryFooter(), WithLinkType(LinkTypeEthernet80211)},
+	} {
+		pcap := NewMemory(opts...)
+		if err := pcap.AddInterface(300, "eth0"); err != nil {
+			tb.Fatal(err)
+		}
+		if err := pcap.SetCaptureStart(time.Unix(1, 0)); err != nil {
+			tb.Fatal(err)
+		}
+		for i := 0; i < 3; i++ {
+			_, err := pcap.WritePacket(Packet{Index: uint16(i), PacketType: PacketTypeUnicast, Len: uint32(i), Data: make([]byte, i)})
+			if err != nil {
+				tb.Fatal(err)
+			}
+		}
+		if err := pcap.Close(); err != nil {
+			tb.Fatal(err)
+		}
+		seeds = append(seeds, pcap.Bytes())
+	}
+	return seeds
+}
+
+func FuzzUnmarshalFileHeader(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		h, _, err := unmarshalFileHeader(b)
+		if err != nil {
+			return
+		}
+		if len(b) >= int(h.size) {
+			// a parsed header can be marshaled back
+			assert.Len(t, marshalFileHeader(h), int(h.size))
+		}
+	})
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync/atomic"
+)
+
+// PacketInfo describes the packet header and its location in the file
+type PacketInfo struct {
+	// Offset of the packet header from the beginning of the file
+	Offset int64
+	// Interface index where frame was received
+	Index uint16
+	// Broadcast/Unicast/Multicast
+	PacketType uint8
+	// Timestamp of the packet, see Packet.Timestamp
+	Timestamp uint32
+	// Original length of captured packet
+	Len uint32
+	// User defined flags
+	Flags uint16
+
+	prev    int64  // time of the previous packet with timestamp deltas
+	elapsed uint32 // low 32 bits of nanoseconds since the capture start
+}
+
+// scan walks headers of all packets from the beginning of the file
+// up to its end, skipping payloads, and calls fn for every header.
+// Headers of concatenated captures are followed transparently.
+// The read offset is not moved.
+func (pcap *PCAP) scan(fn func(info PacketInfo) error) error {
+	fh, err := readFileHeader(pcap.rd, 0, atomic.LoadInt64(&pcap.fsize))
+	if err != nil {
+		return err
+	}
+	return pcap.scanFrom(int64(fh.size), fh, fh.captureStart, func(info PacketInfo, _ *fileHeader) error {
+		return fn(info)
+	})
+}
+
+// scanFrom walks packet headers like scan, starting at offset
+// of the packet belonging to the capture with file header fh, where prev
+// is the time of the previous packet with timestamp deltas.
+// fn also receives the file header of the capture of the packet.
+func (pcap *PCAP) scanFrom(offset int64, fh *fileHeader, prev int64, fn func(info PacketInfo, fh *fileHeader) error) error {
+	fsize := atomic.LoadInt64(&pcap.fsize)
+	pr := pcap.newProgress(fsize)
+	b := make([]byte, extFileSize)
+	count := 0
+	for offset < fsize {
+		hsize := fh.packetHeaderSize()
+		if offset+int64(hsize) > fsize {
+			return &ParseError{Offset: offset, Err: io.ErrUnexpectedEOF}
+		}
+		if _, err := pcap.rd.ReadAt(b[:hsize], offset); err != nil {
+			pcap.lasterr = ErrRead
+			return err
+		}
+		if hasMagic(b) {
+			var err error
+			if fh, err = readFileHeader(pcap.rd, offset, fsize); err != nil {
+				return err
+			}
+			offset += int64(fh.size)
+			prev = fh.captureStart
+			continue
+		}
+		if hasFooterMagic(b) {
+			offset += footerSize
+			continue
+		}
+
+		h, erroffset, err := unmarshalPacketHeader(b[:hsize], fh)
+		if err != nil {
+			return &ParseError{Offset: offset + erroffset, Err: err}
+		}
+		if max := pcap.opts.maxPackets; max > 0 && count >= max {
+			return fmt.Errorf("cannot read more than %d packets: %w", max, ErrTooManyPackets)
+		}
+		count++
+		next := offset + int64(hsize) + int64(h.len)
+		if next > fsize {
+			return &ParseError{Offset: offset + 6, Err: io.ErrUnexpectedEOF}
+		}
+		info := PacketInfo{
+			Offset:     offset,
+			Index:      h.ifindex,
+			PacketType: h.ptype,
+			Timestamp:  h.timestamp,
+			Len:        h.len,
+			Flags:      h.flags,
+			prev:       prev,
+			elapsed:    h.timestamp,
+		}
+		if fh.flags&FlagTimestampDelta != 0 {
+			prev += int64(int32(h.timestamp))
+			info.elapsed = uint32(prev - fh.captureStart)
+		}
+		err = fn(info, fh)
+		if err != nil {
+			return err
+		}
+		offset = next
+		pr.update(offset)
+	}
+	pr.update(fsize)
+	return nil
+}
+
+// BuildIndex scans headers of all packets in the file and returns them in
+// file order. Payloads are skipped and the read offset is not moved.
+func (pcap *PCAP) BuildIndex() ([]PacketInfo, error) {
+	var index []PacketInfo
+	err := pcap.scan(func(info PacketInfo) error {
+		index = append(index, info)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// PacketLen returns the length of data of the packet i, counted from 0 in
+// file order. Only packet headers up to the packet are read and the read
+// offset is not moved.
+func (pcap *PCAP) PacketLen(i int) (uint32, error) {
+	if i < 0 {
+		return 0, errors.New("packet index must not be negative")
+	}
+	var (
+		n   uint32
+		seq int
+	)
+	err := pcap.scan(func(info PacketInfo) error {
+		if seq == i {
+			n = info.Len
+			return errStopScan
+		}
+		seq++
+		return nil
+	})
+	switch err {
+	case errStopScan:
+		return n, nil
+	case nil:
+		return 0, fmt.Errorf("packet %d is out of range, file has %d packets", i, seq)
+	}
+	return 0, err
+}
+
+// SortedForEach calls fn for every packet of the file in the order defined
+// by less, which compares packet headers. Only headers are kept in memory,
+// payloads are read one by one in the sorted order, so the packet and its
+// data are valid only until fn returns. Packets comparing equal keep the
+// file order. The read offset is not moved.
+func (pcap *PCAP) SortedForEach(less func(a, b PacketInfo) bool, fn func(*Packet) error) error {
+	type entry struct {
+		info PacketInfo
+		fh   *fileHeader
+	}
+	fh, err := readFileHeader(pcap.rd, 0, atomic.LoadInt64(&pcap.fsize))
+	if err != nil {
+		return err
+	}
+	var entries []entry
+	err = pcap.scanFrom(int64(fh.size), fh, fh.captureStart, func(info PacketInfo, fh *fileHeader) error {
+		entries = append(entries, entry{info, fh})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return less(entries[i].info, entries[j].info)
+	})
+
+	c := pcap.Clone()
+	p := &Packet{Data: []byte{}}
+	for _, e := range entries {
+		c.h = e.fh
+		c.seekPacket(e.info)
+		if _, err := c.readPacket(p, p.Data); err != nil {
+			return err
+		}
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
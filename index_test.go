@@ -0,0 +1,66 @@
+package lpcap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortedForEach(t *testing.T) {
+	pcap := NewMemory()
+	indexes := []uint16{3, 1, 2, 1, 0}
+	for i, index := range indexes {
+		_, err := pcap.WritePacket(Packet{
+			Index:      index,
+			PacketType: PacketTypeUnicast,
+			Len:        1,
+			Data:       []byte{byte(i)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	offset := pcap.offset
+
+	var (
+		gotIndexes []uint16
+		gotData    []byte
+	)
+	err := pcap.SortedForEach(func(a, b PacketInfo) bool {
+		return a.Index < b.Index
+	}, func(p *Packet) error {
+		gotIndexes = append(gotIndexes, p.Index)
+		gotData = append(gotData, p.Data...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []uint16{0, 1, 1, 2, 3}, gotIndexes)
+	// equal indexes keep the file order
+	assert.Equal(t, []byte{4, 1, 3, 2, 0}, gotData)
+	assert.Equal(t, offset, pcap.offset)
+}
+
+func TestPacketLen(t *testing.T) {
+	pcap := NewMemory()
+	lens := []int{60, 0, 1514, 7}
+	for _, n := range lens {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: uint32(n), Data: make([]byte, n)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	offset := pcap.Tell()
+
+	for i, n := range lens {
+		got, err := pcap.PacketLen(i)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(n), got)
+	}
+	_, err := pcap.PacketLen(len(lens))
+	assert.Error(t, err)
+	_, err = pcap.PacketLen(-1)
+	assert.Error(t, err)
+	assert.Equal(t, offset, pcap.Tell())
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync/atomic"
+)
+
+// Maximum length of interface name in bytes
+const MaxInterfaceName = 255
+
+// AddInterface associates a name with the interface index of packets.
+// Names are stored in the file header, so interfaces must be added before
+// the first packet is written.
+func (pcap *PCAP) AddInterface(index uint16, name string) error {
+	if !pcap.writable {
+		return fmt.Errorf("cannot call AddInterface, file is not opened for writing: %w", ErrUnsupportedOperation)
+	}
+	if len(name) > MaxInterfaceName {
+		return errors.New("cannot add interface, name is too long")
+	}
+	if !pcap.IsEmpty() {
+		return errors.New("cannot add interface after packets have been written")
+	}
+
+	if pcap.h.interfaces == nil {
+		pcap.h.interfaces = make(map[uint16]string)
+	}
+	old, replaced := pcap.h.interfaces[index]
+	flags := pcap.h.flags
+	pcap.h.interfaces[index] = name
+	pcap.h.flags |= FlagInterfaceNames
+	// header length is stored in 16 bits
+	if extFileSize+pcap.h.extSize() > math.MaxUint16 {
+		if replaced {
+			pcap.h.interfaces[index] = old
+		} else {
+			delete(pcap.h.interfaces, index)
+		}
+		pcap.h.flags = flags
+		return errors.New("cannot add interface, file header would exceed 65535 bytes")
+	}
+	return pcap.resizeHeader()
+}
+
+// InterfaceName returns the name associated with the interface index
+func (pcap *PCAP) InterfaceName(index uint16) (string, bool) {
+	name, ok := pcap.h.interfaces[index]
+	return name, ok
+}
+
+// resizeHeader rewrites the file header of a file without packets after
+// its extensions have changed, the file is cut or extended to the header.
+func (pcap *PCAP) resizeHeader() error {
+	pcap.h.size = uint16(extFileSize + pcap.h.extSize())
+	if err := pcap.writeHeader(); err != nil {
+		return err
+	}
+
+	size := int64(pcap.h.size)
+	if t, ok := pcap.rd.(truncater); ok {
+		if err := t.Truncate(size); err != nil {
+			pcap.lasterr = ErrWrite
+			return err
+		}
+	}
+	// move the write position behind the new header
+	if s, ok := pcap.rd.(io.Seeker); ok {
+		if _, err := s.Seek(size, io.SeekStart); err != nil {
+			pcap.lasterr = ErrWrite
+			return err
+		}
+	}
+	atomic.StoreInt64(&pcap.fsize, size)
+	atomic.StoreInt64(&pcap.offset, size)
+	pcap.resetReadAhead()
+	return nil
+}
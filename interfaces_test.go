@@ -0,0 +1,91 @@
+package lpcap
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterfaceNames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "interfaces")
+	pcap, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, pcap.AddInterface(0, "lo"))
+	assert.NoError(t, pcap.AddInterface(7, "eth0"))
+	assert.NoError(t, pcap.AddInterface(0, "lo0"))
+	_, err = pcap.WritePacket(Packet{
+		Index:      7,
+		PacketType: PacketTypeUnicast,
+		Len:        2,
+		Data:       []byte{1, 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, pcap.AddInterface(1, "eth1"))
+	pcap.Close()
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+
+	name, ok := pcap.InterfaceName(0)
+	assert.True(t, ok)
+	assert.Equal(t, "lo0", name)
+	name, ok = pcap.InterfaceName(7)
+	assert.True(t, ok)
+	assert.Equal(t, "eth0", name)
+	_, ok = pcap.InterfaceName(1)
+	assert.False(t, ok)
+
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint16(7), p.Index)
+	assert.Equal(t, []byte{1, 2}, p.Data)
+	assert.False(t, pcap.Next())
+}
+
+func TestInterfaceNamesMemory(t *testing.T) {
+	pcap := NewMemory()
+	assert.NoError(t, pcap.AddInterface(3, "wlan0"))
+
+	pcap, err := OpenMemory(pcap.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, ok := pcap.InterfaceName(3)
+	assert.True(t, ok)
+	assert.Equal(t, "wlan0", name)
+	assert.True(t, pcap.IsEmpty())
+}
+
+func TestInterfaceNamesHeaderLimit(t *testing.T) {
+	pcap := NewMemory()
+	name := strings.Repeat("x", MaxInterfaceName)
+	var i uint16
+	for ; ; i++ {
+		if err := pcap.AddInterface(i, name); err != nil {
+			assert.ErrorContains(t, err, "65535")
+			break
+		}
+	}
+	assert.Greater(t, i, uint16(200))
+	_, ok := pcap.InterfaceName(i)
+	assert.False(t, ok)
+
+	rd, err := OpenMemory(pcap.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := rd.InterfaceName(i - 1)
+	assert.True(t, ok)
+	assert.Equal(t, name, got)
+}
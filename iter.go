@@ -0,0 +1,70 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"io"
+	"iter"
+	"sync/atomic"
+)
+
+var errStopScan = errors.New("scan stopped")
+
+// Packets returns an iterator reading packets from the current offset
+// until the end of the file. Every yielded packet is a new value. Reading
+// stops after the first error, which is yielded with a nil packet.
+func (pcap *PCAP) Packets() iter.Seq2[*Packet, error] {
+	return func(yield func(*Packet, error) bool) {
+		for pcap.Next() {
+			p := new(Packet)
+			if _, err := pcap.ReadPacket(p); err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(p, nil) {
+				return
+			}
+		}
+	}
+}
+
+// Each reads packets from the current offset until the end of the file and
+// calls fn for every one. Data of the packet is reused by the following
+// read, so fn must copy it to keep it. If fn returns ErrStop, possibly
+// wrapped, Each stops and returns nil, other errors of fn or of reading
+// are returned as is.
+func (pcap *PCAP) Each(fn func(Packet) error) error {
+	p := &Packet{Data: []byte{}}
+	for pcap.Next() {
+		if _, err := pcap.readPacket(p, p.Data); err != nil {
+			return err
+		}
+		if err := fn(*p); err != nil {
+			if errors.Is(err, ErrStop) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// HeadersOnly returns an iterator over headers of packets from the current
+// offset until the end of the file. Payloads are skipped, which makes it
+// much faster than Packets for large files. The read offset is not moved.
+// Iteration stops after the first error, which is yielded last.
+func (pcap *PCAP) HeadersOnly() iter.Seq2[PacketInfo, error] {
+	return func(yield func(PacketInfo, error) bool) {
+		err := pcap.scanFrom(atomic.LoadInt64(&pcap.offset), pcap.h, pcap.prevTime, func(info PacketInfo, _ *fileHeader) error {
+			if !yield(info, nil) {
+				return errStopScan
+			}
+			return nil
+		})
+		if err != nil && err != errStopScan && err != io.EOF {
+			yield(PacketInfo{}, err)
+		}
+	}
+}
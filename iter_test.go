@@ -0,0 +1,108 @@
+package lpcap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPackets(t *testing.T) {
+	pcap := createSequence(t, 10)
+	defer pcap.Close()
+
+	var indexes []uint16
+	for p, err := range pcap.Packets() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexes = append(indexes, p.Index)
+		if len(indexes) == 4 {
+			break
+		}
+	}
+	assert.Equal(t, []uint16{0, 1, 2, 3}, indexes)
+
+	// continues from where the loop stopped
+	for info, err := range pcap.HeadersOnly() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		indexes = append(indexes, info.Index)
+	}
+	assert.Equal(t, []uint16{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, indexes)
+	assert.True(t, pcap.Next())
+}
+
+func TestEach(t *testing.T) {
+	pcap := createSequence(t, 10)
+	defer pcap.Close()
+
+	var indexes []uint16
+	err := pcap.Each(func(p Packet) error {
+		indexes = append(indexes, p.Index)
+		if len(indexes) == 2 {
+			return ErrStop
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{0, 1}, indexes)
+
+	// continues after the second packet, errors of fn are returned
+	errFail := errors.New("fail")
+	err = pcap.Each(func(p Packet) error {
+		indexes = append(indexes, p.Index)
+		return errFail
+	})
+	assert.Equal(t, errFail, err)
+	assert.Equal(t, []uint16{0, 1, 2}, indexes)
+
+	// wrapped ErrStop stops as well
+	err = pcap.Each(func(p Packet) error {
+		indexes = append(indexes, p.Index)
+		return fmt.Errorf("packet %d: %w", p.Index, ErrStop)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint16{0, 1, 2, 3}, indexes)
+
+	assert.NoError(t, pcap.Each(func(p Packet) error {
+		indexes = append(indexes, p.Index)
+		return nil
+	}))
+	assert.Len(t, indexes, 10)
+	assert.False(t, pcap.Next())
+}
+
+func BenchmarkPackets(b *testing.B) {
+	pcap := createSequence(b, 10000)
+	defer pcap.Close()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := pcap.SeekOffset(int64(pcap.h.size), io.SeekStart); err != nil {
+			b.Fatal(err)
+		}
+		for _, err := range pcap.Packets() {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkHeadersOnly(b *testing.B) {
+	pcap := createSequence(b, 10000)
+	defer pcap.Close()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for _, err := range pcap.HeadersOnly() {
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
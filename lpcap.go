@@ -4,16 +4,18 @@
 package lpcap
 
 import (
-	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"sync"
 	"sync/atomic"
+	"syscall"
+	"time"
 )
 
 const MajorVer = 1
-const MinorVer = 0
+const MinorVer = 2
 
 type ReaderWriterCloser interface {
 	io.Reader
@@ -31,10 +33,22 @@ type PCAP struct {
 	h        *fileHeader
 	rd       ReaderWriterCloser
 	len      int32 // count of total packets
+	written  int64 // count of packets written in this session
 	offset   int64 // read offset of PCAP file
 	isClosed bool
+	writable bool // header can be rewritten in place
+	isClone  bool // shares the file with the original PCAP
 	lasterr  ErrorCode
 	fsize    int64
+	opts     options
+	wbuf     []byte // reused write buffer of preallocated files
+	metrics  metrics
+	summary  Summary  // packets written in this session, for the footer
+	footer   *Summary // summary footer read from the file
+	ra       []byte   // read-ahead buffer, see WithReadAhead
+	prevTime int64    // time of the previous packet read with timestamp deltas
+	lastTime int64    // time of the last packet written with timestamp deltas
+	raOff    int64    // offset of the read-ahead buffer in the file
 	mx       *sync.RWMutex
 	closeMx  *sync.Mutex
 }
@@ -42,7 +56,7 @@ type PCAP struct {
 // Packet represents information about the captured packet
 type Packet struct {
 	// Interface index where frame was received
-	Index uint8
+	Index uint16
 	// Broadcast/Unicast/Multicast
 	PacketType uint8
 	// Represents the number of nanoseconds that have elapsed since 1970-01-01 00:00:00 UTC
@@ -51,6 +65,11 @@ type Packet struct {
 	Len uint32
 	// Raw packet data
 	Data []byte
+	// User defined flags, stored only if the file has FlagPacketFlags set
+	Flags uint16
+
+	start  int64       // capture start of the file in nanoseconds, if known
+	region *mmapRegion // mapping Data is borrowed from, kept alive by the packet
 }
 
 type LinkType uint32
@@ -69,64 +88,107 @@ const (
 	LinkTypeFDDI
 )
 
+// supported reports whether files with the link type can be written and read
+func (lt LinkType) supported() bool {
+	return lt == LinkTypeEthernet2 || lt == LinkTypeEthernet80211
+}
+
 // Maximum frame length that can be captured
 const MaxSnapLength = 1<<14 - 1
 
+// Packet types. Although the values are distinct bits, a packet has
+// exactly one type, since a frame is addressed either to every host, to a
+// single host or to a group. Combinations of types are rejected on read.
 const (
 	PacketTypeBroadcast = 2 << iota // broadcast packet type
 	PacketTypeUnicast               // unicast packet type
 	PacketTypeMulticast             // multicast packet type
 )
 
-var packetPool = &sync.Pool{
-	New: func() any {
-		return make([]byte, 0, MaxSnapLength)
-	},
+// Creates a PCAP file on the specified path,
+// writes the file header and returns the PCAP
+// structure and an error if the file creation failed.
+// An existing file is truncated and overwritten.
+func Create(path string, opts ...Option) (*PCAP, error) {
+	return create(path, os.O_TRUNC, newOptions(opts))
 }
 
-// Creates a PCAP file on the specified path,
-// writes the first 14 bytes of the file header and returns the PCAP
-// structure and an error if the file creation failed
-func Create(path string) (*PCAP, error) {
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, os.ModePerm)
+// CreateExclusive creates a PCAP file like Create, but fails with an error
+// matching os.ErrExist if the file already exists, instead of overwriting it
+func CreateExclusive(path string, opts ...Option) (*PCAP, error) {
+	return create(path, os.O_EXCL, newOptions(opts))
+}
+
+// create opens the file on path with flags added to the open flags
+// of options and writes the file header
+func create(path string, flags int, o options) (*PCAP, error) {
+	f, err := os.OpenFile(path, o.openFlags()|flags, o.mode)
+	if err != nil {
+		return nil, err
+	}
+
+	pcap, err := newWriter(f, o)
 	if err != nil {
+		f.Close()
 		return nil, err
 	}
+	return pcap, nil
+}
+
+// NewWriter writes the file header to rw, such as a MemBuffer, and
+// returns the PCAP structure for writing packets after it
+func NewWriter(rw ReaderWriterCloser, opts ...Option) (*PCAP, error) {
+	return newWriter(rw, newOptions(opts))
+}
 
+// newWriter writes the file header to rw and returns
+// the PCAP structure for writing packets after it
+func newWriter(rw ReaderWriterCloser, o options) (*PCAP, error) {
+	if o.snapLen < minPacketSize || o.snapLen > MaxSnapLength {
+		return nil, errors.New("snap length must be between packet header length and MaxSnapLength")
+	}
+	if !o.link.supported() {
+		return nil, errors.New("link type is undefined")
+	}
 	p := &PCAP{
 		h: &fileHeader{
 			mx:       lpcapmx,
 			majorVer: MajorVer,
 			minorVer: MinorVer,
-			snapLen:  MaxSnapLength,
-			link:     LinkTypeEthernet2,
+			snapLen:  o.snapLen,
+			link:     o.link,
+			flags:    o.flags | FlagHeaderChecksum,
 		},
-		rd:      f,
-		len:     0,
-		offset:  0,
-		lasterr: ErrOk,
-		mx:      new(sync.RWMutex),
-		closeMx: new(sync.Mutex),
-	}
-
-	b := make([]byte, minFileSize)
-	binary.LittleEndian.PutUint16(b, p.h.mx)
-	binary.LittleEndian.PutUint16(b[2:], p.h.majorVer)
-	binary.LittleEndian.PutUint16(b[4:], p.h.minorVer)
-	binary.LittleEndian.PutUint32(b[6:], p.h.snapLen)
-	binary.LittleEndian.PutUint32(b[10:], uint32(p.h.link))
-	n, err := f.Write(b)
+		opts:     o,
+		rd:       rw,
+		len:      0,
+		offset:   0,
+		writable: true,
+		lasterr:  ErrOk,
+		mx:       new(sync.RWMutex),
+		closeMx:  new(sync.Mutex),
+	}
+	p.h.size = uint16(extFileSize + p.h.extSize())
+	// the count of the empty file is correct
+	p.h.countEnd = int64(p.h.size)
+
+	n, err := rw.Write(marshalFileHeader(p.h))
 	if err != nil {
 		return nil, err
 	}
 	p.offset += int64(n)
-	p.fsize = minFileSize
+	p.fsize = int64(n)
+	if o.prealloc > 0 {
+		if err := p.preallocate(); err != nil {
+			return nil, err
+		}
+	}
 	return p, nil
 }
 
-// Open a PCAP file, reads the first 14 bytes of the header,
+// Open a PCAP file, reads the file header,
 // verifying header and returns the PCAP structure.
-func Open(path string) (*PCAP, error) {
+func Open(path string, opts ...Option) (*PCAP, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -134,120 +196,448 @@ func Open(path string) (*PCAP, error) {
 
 	s, err := f.Stat()
 	if err != nil {
+		f.Close()
 		return nil, err
 	}
 
-	fileSize := s.Size()
-	if fileSize < minFileSize {
-		return nil, errors.New("file length too small, cannot read file header")
+	pcap, err := newReader(f, s.Size(), newOptions(opts))
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return pcap, nil
+}
+
+// OpenRW opens an existing PCAP file for reading and appending. Packets
+// are read from the first one, while WritePacket appends after the last.
+// Appending to concatenated captures is not supported.
+func OpenRW(path string, opts ...Option) (*PCAP, error) {
+	return openRW(path, false, newOptions(opts))
+}
+
+// openRW opens an existing file for reading and appending,
+// reading through a memory mapping if mapped is set
+func openRW(path string, mapped bool, o options) (*PCAP, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
 	}
 
-	// read first 14 file header bytes and then unmarshal and parse
-	b := make([]byte, minFileSize)
-	n, err := f.ReadAt(b, 0)
+	var rw ReaderWriterCloser = f
+	if mapped {
+		rw = &remapFile{File: f}
+	}
+	pcap, err := newReader(rw, s.Size(), o)
 	if err != nil {
+		rw.Close()
+		return nil, err
+	}
+	if pcap.h.flags&FlagSummaryFooter != 0 {
+		if err := pcap.reopenFooter(); err != nil {
+			rw.Close()
+			return nil, err
+		}
+	}
+	if pcap.h.flags&FlagPacketCount != 0 {
+		n, err := pcap.Count()
+		if err != nil {
+			rw.Close()
+			return nil, err
+		}
+		pcap.h.packetCount = int64(n)
+	}
+	if pcap.h.flags&FlagTimestampDelta != 0 {
+		// appended packets continue from the last one
+		t, err := pcap.timeAt(pcap.fsize)
+		if err != nil {
+			rw.Close()
+			return nil, err
+		}
+		pcap.lastTime = t
+	}
+	// reads use explicit offsets, so the file position is the write offset
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		rw.Close()
 		return nil, err
 	}
+	pcap.writable = true
+	return pcap, nil
+}
+
+// NewReader verifies the file header of rw, such as a MemBuffer,
+// containing size bytes and returns the PCAP structure for reading
+func NewReader(rw ReaderWriterCloser, size int64, opts ...Option) (*PCAP, error) {
+	return newReader(rw, size, newOptions(opts))
+}
+
+// newReader verifies the file header of rw containing size bytes and
+// returns the PCAP structure positioned at the first packet
+func newReader(rw ReaderWriterCloser, size int64, o options) (*PCAP, error) {
+	if size < minFileSize {
+		return nil, errors.New("file length too small, cannot read file header")
+	}
 
+	// read file header bytes and then unmarshal and parse,
 	// discard PCAP file if header is invalid
-	header, erroffset, err := unmarshalFileHeader(b)
+	header, err := readFileHeader(rw, 0, size)
 	if err != nil {
-		return nil, &ParseError{Offset: erroffset, Err: err}
+		return nil, err
 	}
 
 	pcap := &PCAP{
-		h:       header,
-		rd:      f,
-		len:     0,
-		offset:  int64(n),
-		fsize:   fileSize,
-		mx:      new(sync.RWMutex),
-		closeMx: new(sync.Mutex),
+		h:        header,
+		rd:       rw,
+		len:      0,
+		offset:   int64(header.size),
+		fsize:    size,
+		prevTime: header.captureStart,
+		lastTime: header.captureStart,
+		opts:     o,
+		mx:       new(sync.RWMutex),
+		closeMx:  new(sync.Mutex),
 	}
+	pcap.readFooter()
 	return pcap, nil
 }
 
 // Reads packet header from the current offset.
-// Reads first 12 bytes of packet header, determines frame size, checks timestamp,
+// Reads the packet header, validates packet type, determines frame size,
 // then reads file to size specified in packet header.
+//
+// Several captures concatenated into one file are read as a single stream,
+// the file header of each following capture is detected at the packet
+// boundary and skipped transparently.
+//
+// By default Data is a new allocation owned by the caller. With
+// WithCopyData(false) Data is a buffer of the internal packet pool, which
+// is reused by following reads and writes, so it must not be retained.
+// With WithBorrowed Data points into the memory mapping of the file.
+// Use ReadPacketCopy to get owned Data regardless of the options.
 func (pcap *PCAP) ReadPacket(p *Packet) (n int, err error) {
-	b := packetPool.Get().([]byte)
-	b = b[:0]
-	b = b[:minPacketSize]
-	n, err = pcap.rd.ReadAt(b, atomic.LoadInt64(&pcap.offset))
-	if err != nil {
-		if err == io.EOF {
-			pcap.lasterr = ErrNoMorePacket
-		} else {
-			pcap.lasterr = ErrRead
+	if pcap.opts.copyData {
+		return pcap.readPacket(p, []byte{})
+	}
+	return pcap.readPacket(p, nil)
+}
+
+// SetPayloadValidator sets fn to be called with every packet read after it
+// was decoded, for integrity checks specific to the payload such as an
+// internal CRC. A packet rejected by fn is consumed, the read returns a
+// ValidationError with the index of the packet wrapping both
+// ErrInvalidPayload and the error of fn. A nil fn removes the validator.
+func (pcap *PCAP) SetPayloadValidator(fn func(Packet) error) {
+	pcap.opts.validator = fn
+}
+
+// ReadPacketCopy reads the packet like ReadPacket, but Data is always a
+// new allocation owned by the caller, independent of the packet pool and
+// of the memory mapping, whatever WithCopyData and WithBorrowed are.
+func (pcap *PCAP) ReadPacketCopy(p *Packet) (int, error) {
+	n, err := pcap.readPacket(p, []byte{})
+	if err == nil && p.region != nil {
+		data := make([]byte, len(p.Data))
+		copy(data, p.Data)
+		p.Data, p.region = data, nil
+	}
+	return n, err
+}
+
+// PeekN reads up to k packets from the current offset without consuming
+// them, the following reads return the same packets again. Fewer packets
+// are returned if the end of the file is reached. Data of every packet is
+// a separate allocation.
+func (pcap *PCAP) PeekN(k int) ([]Packet, error) {
+	h, offset, n, prevTime := pcap.h, atomic.LoadInt64(&pcap.offset), atomic.LoadInt32(&pcap.len), pcap.prevTime
+	defer func() {
+		pcap.h, pcap.prevTime = h, prevTime
+		atomic.StoreInt64(&pcap.offset, offset)
+		atomic.StoreInt32(&pcap.len, n)
+	}()
+
+	var packets []Packet
+	for len(packets) < k && pcap.Next() {
+		var p Packet
+		if _, err := pcap.readNext(&p, []byte{}); err != nil {
+			return packets, err
+		}
+		packets = append(packets, p)
+	}
+	return packets, nil
+}
+
+// readPacket reads the packet at the current offset into p. The payload
+// is stored in buf if it is not nil, growing it when needed, otherwise
+// in a buffer of the packet pool.
+func (pcap *PCAP) readPacket(p *Packet, buf []byte) (int, error) {
+	n, err := pcap.readNext(p, buf)
+	if err == nil && pcap.opts.validator != nil {
+		if verr := pcap.opts.validator(*p); verr != nil {
+			index := int(atomic.LoadInt32(&pcap.len)) - 1
+			err = &ValidationError{Index: index, Err: fmt.Errorf("%w: %w", ErrInvalidPayload, verr)}
+		}
+	}
+	pcap.recordRead(n, err)
+	return n, err
+}
+
+// readNext implements readPacket without updating metrics
+func (pcap *PCAP) readNext(p *Packet, buf []byte) (n int, err error) {
+	if max := pcap.opts.maxPackets; max > 0 && int(atomic.LoadInt32(&pcap.len)) >= max {
+		pcap.lasterr = ErrRead
+		return 0, fmt.Errorf("cannot read more than %d packets: %w", max, ErrTooManyPackets)
+	}
+	hsize := pcap.h.packetHeaderSize()
+	b := pcap.getBuffer(hsize)
+	offset := atomic.LoadInt64(&pcap.offset)
+	n, err = pcap.readAt(b, offset)
+	switch {
+	case n == hsize:
+		// ReaderAt may return io.EOF with the last bytes of the file
+	case err == io.EOF && n == 0:
+		pcap.lasterr = ErrNoMorePacket
+		return 0, err
+	case err == io.EOF:
+		pcap.lasterr = ErrRead
+		return 0, &ParseError{Offset: offset, Err: ErrTruncatedPacket}
+	default:
+		pcap.lasterr = ErrRead
+		if err == nil {
+			err = io.ErrUnexpectedEOF
 		}
 		return 0, err
 	}
-	atomic.AddInt64(&pcap.offset, int64(n))
+	if hasMagic(b) {
+		pcap.putBuffer(b)
+		if err := pcap.readEmbeddedHeader(); err != nil {
+			return 0, err
+		}
+		return pcap.readNext(p, buf)
+	}
+	if hasFooterMagic(b) {
+		// footer of a concatenated capture
+		pcap.putBuffer(b)
+		atomic.AddInt64(&pcap.offset, footerSize)
+		return pcap.readNext(p, buf)
+	}
+	start := atomic.AddInt64(&pcap.offset, int64(n)) - int64(n)
 
-	// Unmarshal packet header with maximum snap length
-	h, erroffset, err := unmarshalPacketHeader(b, pcap.h.snapLen)
+	// Unmarshal packet header with maximum snap length,
+	// error offsets are relative to the start of the header
+	h, erroffset, err := unmarshalPacketHeader(b, pcap.h)
 	if err != nil {
-		erroffset += atomic.LoadInt64(&pcap.offset)
 		pcap.lasterr = ErrInvalidHeader
-		return 0, &ParseError{Offset: erroffset, Err: err}
+		return 0, &ParseError{Offset: start + erroffset, Err: err}
+	}
+	if max := pcap.opts.maxPacketSize; max > 0 && h.len > max {
+		pcap.lasterr = ErrSizeOverflow
+		atomic.AddUint64(&pcap.metrics.oversizeDrops, 1)
+		return 0, &ParseError{
+			Offset: start + 6,
+			Err:    errors.New("length of packet exceeds maximum packet size"),
+		}
 	}
 
-	b = b[:h.len]
-	n, err = pcap.rd.ReadAt(b, atomic.LoadInt64(&pcap.offset))
+	pcap.putBuffer(b)
+	var region *mmapRegion
+	br := pcap.borrower()
+	switch {
+	case br != nil:
+		// payload is sliced from the mapping without copying
+	case buf == nil:
+		b = pcap.getBuffer(int(h.len))
+		defer pcap.putBuffer(b)
+	case cap(buf) < int(h.len):
+		b = make([]byte, h.len)
+	default:
+		b = buf[:h.len]
+	}
+	if br != nil {
+		b, region, err = br.borrow(atomic.LoadInt64(&pcap.offset), int(h.len))
+		n = len(b)
+	} else {
+		n, err = pcap.readAt(b, atomic.LoadInt64(&pcap.offset))
+	}
+	if err == io.EOF && n == int(h.len) {
+		// the packet ends the file
+		err = nil
+	}
+	if err == io.EOF {
+		// the header was read, so the file is cut inside the payload
+		err = io.ErrUnexpectedEOF
+	}
 	if err != nil {
-		if err == io.EOF {
-			pcap.lasterr = ErrNoMorePacket
-		} else {
-			pcap.lasterr = ErrRead
-		}
+		pcap.lasterr = ErrRead
 		return 0, err
 	}
-	packetPool.Put(b)
+	// ReaderAt must fail on short reads, but do not trust all of them
+	if n != int(h.len) {
+		pcap.lasterr = ErrRead
+		return 0, io.ErrUnexpectedEOF
+	}
 
+	// in delta mode the packet keeps its raw delta, so its base is the
+	// time of the previous packet
+	base := pcap.h.captureStart
+	if pcap.h.flags&FlagTimestampDelta != 0 {
+		pcap.prevTime += int64(int32(h.timestamp))
+		base = pcap.prevTime - int64(h.timestamp)
+	}
 	*p = Packet{
 		Index:      h.ifindex,
 		PacketType: h.ptype,
 		Timestamp:  h.timestamp,
 		Len:        h.len,
 		Data:       b,
+		Flags:      h.flags,
+		start:      base,
+		region:     region,
 	}
 	atomic.AddInt32(&pcap.len, 1)
 	atomic.AddInt64(&pcap.offset, int64(n))
-	return minPacketSize + n, nil
+	return hsize + n, nil
+}
+
+// readEmbeddedHeader parses the file header of a concatenated capture at
+// the current offset, makes it the active header and moves past it.
+func (pcap *PCAP) readEmbeddedHeader() error {
+	offset := atomic.LoadInt64(&pcap.offset)
+	header, err := readFileHeader(pcap.rd, offset, atomic.LoadInt64(&pcap.fsize))
+	if err != nil {
+		pcap.lasterr = ErrInvalidHeader
+		return err
+	}
+	pcap.h = header
+	pcap.prevTime = header.captureStart
+	atomic.AddInt64(&pcap.offset, int64(header.size))
+	return nil
 }
 
 // Writes timestamp, data into a PacketHeader structure and then into
 // a byte array. Writes the data to a file and flushes it.
 func (pcap *PCAP) WritePacket(p Packet) (n int, err error) {
-	isOverflow := len(p.Data)+minPacketSize > int(pcap.h.snapLen)
-	if isOverflow {
-		pcap.lasterr = ErrSizeOverflow
-		return 0, errors.New("cannot write packet to PCAP, because length of packet greater than snap length")
-	}
-
-	offset := 0
-	b := packetPool.Get().([]byte)
-	b = b[:0]
-	b = b[:minPacketSize+p.Len]
-	b[0] = p.Index
-	b[1] = p.PacketType
-	offset += 2
-	binary.LittleEndian.PutUint32(b[offset:], p.Timestamp)
-	offset += 4
-	binary.LittleEndian.PutUint32(b[offset:], p.Len)
-	offset += 4
+	defer func() { pcap.recordWrite(n, err) }()
+	if !pcap.writable {
+		pcap.lasterr = ErrWrite
+		return 0, fmt.Errorf("cannot call WritePacket, file is opened read-only: %w", ErrUnsupportedOperation)
+	}
+	if code, err := pcap.validatePacket(&p); err != nil {
+		pcap.lasterr = code
+		if code == ErrSizeOverflow {
+			atomic.AddUint64(&pcap.metrics.oversizeDrops, 1)
+		}
+		return 0, err
+	}
+	if need := uint32(len(p.Data) + minPacketSize); need > pcap.h.snapLen {
+		// only reachable with adaptive snap length
+		if err := pcap.growSnapLength(need); err != nil {
+			return 0, err
+		}
+	}
+
+	if pcap.opts.autoTimestamp {
+		i := time.Duration(atomic.LoadInt64(&pcap.written))
+		t := pcap.opts.tsStart.Add(pcap.opts.tsStep * i)
+		if pcap.h.flags&FlagTimestampDelta != 0 {
+			ts, err := pcap.deltaTimestamp(t)
+			if err != nil {
+				pcap.lasterr = ErrWrite
+				return 0, err
+			}
+			p.Timestamp = ts
+		} else {
+			p.Timestamp = uint32(t.UnixNano())
+		}
+	}
+	size := pcap.h.packetHeaderSize() + int(p.Len)
+	if max := pcap.opts.maxFileSize; max > 0 {
+		end := atomic.LoadInt64(&pcap.fsize) + int64(size)
+		if pcap.h.flags&FlagSummaryFooter != 0 {
+			end += footerSize
+		}
+		if end > max {
+			pcap.lasterr = ErrCaptureFull
+			return 0, fmt.Errorf("cannot write packet to PCAP, file would exceed maximum file size: %w", ErrCaptureFull)
+		}
+	}
+	if pcap.opts.prealloc > 0 {
+		return pcap.writePacketAt(&p, size)
+	}
+
+	b := pcap.getBuffer(size)
+	offset := marshalPacketHeader(b, &p, pcap.h)
 	copy(b[offset:], p.Data)
 	n, err = pcap.rd.Write(b)
 	if err != nil {
 		pcap.lasterr = ErrWrite
 		return 0, err
 	}
-	atomic.AddInt64(&pcap.fsize, int64(n))
-	packetPool.Put(b)
+	pcap.account(n, &p)
+	pcap.putBuffer(b)
 	return n, err
 }
 
+// account updates the file size and the state of written packets
+// after p of n bytes was written
+func (pcap *PCAP) account(n int, p *Packet) {
+	atomic.AddInt64(&pcap.fsize, int64(n))
+	atomic.AddInt64(&pcap.written, 1)
+	pcap.h.packetCount++
+	if pcap.h.flags&FlagTimestampDelta != 0 {
+		// the summary keeps time since the capture start, not deltas
+		pcap.lastTime += int64(int32(p.Timestamp))
+		pcap.summary.add(uint32(pcap.lastTime - pcap.h.captureStart))
+	} else {
+		pcap.summary.add(p.Timestamp)
+	}
+}
+
+// validatePacket checks that the packet can be written and read back,
+// returning the error code to be set as the last error
+func (pcap *PCAP) validatePacket(p *Packet) (ErrorCode, error) {
+	snapLen := pcap.h.snapLen
+	if pcap.opts.adaptiveSnapLen {
+		snapLen = MaxSnapLength
+	}
+	isOverflow := len(p.Data)+minPacketSize > int(snapLen)
+	if isOverflow {
+		return ErrSizeOverflow, errors.New("cannot write packet to PCAP, because length of packet greater than snap length")
+	}
+	if int(p.Len) != len(p.Data) {
+		return ErrInvalidHeader, errors.New("cannot write packet to PCAP, because length of packet differs from length of data")
+	}
+	if !isValidPacketType(p.PacketType) {
+		return ErrInvalidHeader, errors.New("cannot write packet to PCAP, because packet type is undefined")
+	}
+	if !pcap.h.hasWideIndex() && p.Index > 0xff {
+		return ErrInvalidHeader, errors.New("cannot write packet to PCAP, because file version supports only 8-bit interface index")
+	}
+	return ErrOk, nil
+}
+
+// ValidatePacket checks that the packet can be written to the file
+// without writing it, see ValidateWrite for a batch of packets
+func (pcap *PCAP) ValidatePacket(p Packet) error {
+	_, err := pcap.validatePacket(&p)
+	return err
+}
+
+// ValidateWrite checks that all packets can be written to the file without
+// writing anything. The first invalid packet is reported by ValidationError.
+func (pcap *PCAP) ValidateWrite(ps []Packet) error {
+	for i := range ps {
+		if _, err := pcap.validatePacket(&ps[i]); err != nil {
+			return &ValidationError{Index: i, Err: err}
+		}
+	}
+	return nil
+}
+
 // Next return true if current readed offset less than summary file length
 func (pcap *PCAP) Next() bool {
 	pcap.mx.RLock()
@@ -256,21 +646,147 @@ func (pcap *PCAP) Next() bool {
 	return hasNext
 }
 
-// Close clears the fields and then closes the file descriptor
+// Size returns the size of the file in bytes, excluding the summary footer
+func (pcap *PCAP) Size() int64 {
+	return atomic.LoadInt64(&pcap.fsize)
+}
+
+// Remaining returns the count of bytes from the read offset to the end
+// of the file, which is zero once all packets have been read
+func (pcap *PCAP) Remaining() int64 {
+	pcap.mx.RLock()
+	remaining := atomic.LoadInt64(&pcap.fsize) - atomic.LoadInt64(&pcap.offset)
+	pcap.mx.RUnlock()
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// Tell returns the current read offset, which can be restored by SetOffset
+func (pcap *PCAP) Tell() int64 {
+	return atomic.LoadInt64(&pcap.offset)
+}
+
+// SetOffset sets the read offset to off from the beginning of the file,
+// usually an offset returned by Tell. See SeekOffset for valid offsets.
+func (pcap *PCAP) SetOffset(off int64) error {
+	_, err := pcap.SeekOffset(off, io.SeekStart)
+	return err
+}
+
+// SeekOffset sets the read offset according to whence, following io.Seeker
+// semantics: io.SeekStart is relative to the beginning of the file,
+// io.SeekCurrent to the current read offset and io.SeekEnd to the end of
+// the file. The resulting offset must lie between the end of the file
+// header and the end of the file. It is not checked to be a packet boundary.
+func (pcap *PCAP) SeekOffset(off int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		off += atomic.LoadInt64(&pcap.offset)
+	case io.SeekEnd:
+		off += atomic.LoadInt64(&pcap.fsize)
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if off < int64(pcap.h.size) || off > atomic.LoadInt64(&pcap.fsize) {
+		return 0, errors.New("seek offset is out of packets range")
+	}
+	if err := pcap.seekTo(off); err != nil {
+		return 0, err
+	}
+	return off, nil
+}
+
+// Close flushes and syncs written data, clears the fields and then closes
+// the file descriptor. Errors of every step are combined. Closing a file
+// right after Create leaves a valid capture with the file header only.
 func (pcap *PCAP) Close() error {
 	pcap.closeMx.Lock()
 	defer pcap.closeMx.Unlock()
 	if pcap.isClosed {
 		return errors.New("file is already closed")
 	}
+	var flushErr error
+	if pcap.writable {
+		if pcap.h.flags&FlagPacketCount != 0 {
+			flushErr = pcap.writeCount()
+		}
+		if pcap.h.flags&FlagSummaryFooter != 0 {
+			flushErr = errors.Join(flushErr, pcap.writeFooter())
+		}
+		if pcap.opts.prealloc > 0 {
+			flushErr = errors.Join(flushErr, pcap.truncate())
+		}
+		flushErr = errors.Join(flushErr, pcap.flush())
+	}
+	if pcap.isClone {
+		pcap.isClosed = true
+		return flushErr
+	}
 	pcap.h = nil
 	pcap.len = 0
 	pcap.offset = 0
 	pcap.isClosed = true
 	pcap.lasterr = ErrOk
 	pcap.fsize = 0
+	if f, ok := pcap.rd.(atomicFile); ok && flushErr != nil {
+		// an incomplete capture of CreateAtomic is not renamed to its path
+		pcap.rd = tempFile{f.File}
+	}
 	err := pcap.rd.Close()
-	return err
+	return errors.Join(flushErr, err)
+}
+
+// flush writes buffered data of the writer down to the storage
+func (pcap *PCAP) flush() error {
+	if f, ok := pcap.rd.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	if s, ok := pcap.rd.(interface{ Sync() error }); ok {
+		// pipes and terminals cannot be synced
+		if err := s.Sync(); err != nil && !errors.Is(err, syscall.EINVAL) {
+			return err
+		}
+	}
+	return nil
+}
+
+// FinishWriting flushes and syncs the packets written so far and returns
+// a read-only PCAP positioned at the first packet, sharing the file with
+// pcap like a Clone. The returned PCAP sees only the packets written before
+// the call, and pcap must outlive it.
+func (pcap *PCAP) FinishWriting() (*PCAP, error) {
+	if !pcap.writable {
+		return nil, fmt.Errorf("cannot call FinishWriting, file is opened read-only: %w", ErrUnsupportedOperation)
+	}
+	if err := pcap.flush(); err != nil {
+		return nil, err
+	}
+	return pcap.rewound()
+}
+
+// Clone returns a read-only PCAP sharing the file with pcap, but with its
+// own read offset positioned at the current offset of pcap. Clones can be
+// read concurrently with each other. Closing a clone does not close the
+// file, the original PCAP must outlive all of its clones.
+func (pcap *PCAP) Clone() *PCAP {
+	h := *pcap.h
+	return &PCAP{
+		h:        &h,
+		rd:       pcap.rd,
+		offset:   atomic.LoadInt64(&pcap.offset),
+		fsize:    atomic.LoadInt64(&pcap.fsize),
+		isClone:  true,
+		opts:     pcap.opts,
+		footer:   pcap.footer,
+		prevTime: pcap.prevTime,
+		mx:       new(sync.RWMutex),
+		closeMx:  new(sync.Mutex),
+	}
 }
 
 // Len returns the size of the packets read from the file
@@ -278,14 +794,68 @@ func (pcap *PCAP) Len() int {
 	return int(atomic.LoadInt32(&pcap.len))
 }
 
+// DataOffset returns the offset of the first packet from the beginning of
+// the file, which depends on the format version and the header extensions.
+// When concatenated captures are read, it is the offset of the first packet
+// from the beginning of the capture being read.
+func (pcap *PCAP) DataOffset() int64 {
+	pcap.mx.RLock()
+	off := int64(pcap.h.size)
+	pcap.mx.RUnlock()
+	return off
+}
+
+// IsEmpty reports whether the file consists only of the file header
+func (pcap *PCAP) IsEmpty() bool {
+	return atomic.LoadInt64(&pcap.fsize) == int64(pcap.h.size)
+}
+
 // LinkType returns link layer of packets in the file
 func (pcap *PCAP) LinkType() LinkType {
 	return pcap.h.link
 }
 
-// SetLinkType setup file frame format link type
-func (pcap *PCAP) SetLinkType(lt LinkType) {
+// SetLinkType setup file frame format link type. If the file was
+// created for writing, the file header is rewritten with the new link type.
+func (pcap *PCAP) SetLinkType(lt LinkType) error {
+	if !lt.supported() {
+		return errors.New("link type is undefined")
+	}
+	old := pcap.h.link
 	pcap.h.link = lt
+	if !pcap.writable {
+		return nil
+	}
+	if err := pcap.writeHeader(); err != nil {
+		pcap.h.link = old
+		return err
+	}
+	return nil
+}
+
+// growSnapLength raises the snap length of the file to n,
+// rewriting the file header
+func (pcap *PCAP) growSnapLength(n uint32) error {
+	old := pcap.h.snapLen
+	pcap.h.snapLen = n
+	if err := pcap.writeHeader(); err != nil {
+		pcap.h.snapLen = old
+		return err
+	}
+	return nil
+}
+
+// writeHeader rewrites the file header at the beginning of the file
+func (pcap *PCAP) writeHeader() error {
+	w, ok := pcap.rd.(io.WriterAt)
+	if !ok {
+		return errors.New("cannot rewrite file header, writer does not support WriteAt")
+	}
+	if _, err := w.WriteAt(marshalFileHeader(pcap.h), 0); err != nil {
+		pcap.lasterr = ErrWrite
+		return err
+	}
+	return nil
 }
 
 // LastError returns the internal representation of the last error
@@ -1,7 +1,17 @@
 package lpcap
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
 	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -9,10 +19,7 @@ import (
 )
 
 func TestReadPacket(t *testing.T) {
-	pcap, err := Create("0pcap")
-	if err != nil {
-		t.Fatal(err)
-	}
+	pcap := NewMemory()
 	defer pcap.Close()
 
 	data := make([]byte, 128)
@@ -32,7 +39,7 @@ func TestReadPacket(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	assert.Equal(t, minPacketSize+len(data), n)
+	assert.Equal(t, createdPacketSize+len(data), n)
 
 	pp := new(Packet)
 	n, err = pcap.ReadPacket(pp)
@@ -40,14 +47,374 @@ func TestReadPacket(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	assert.Equal(t, data, p.Data)
-	assert.Equal(t, uint8(4), p.Index)
-	assert.Equal(t, uint8(PacketTypeBroadcast), p.PacketType)
-	assert.Equal(t, uint32(128), p.Len)
+	assert.Equal(t, createdPacketSize+len(data), n)
+	assert.Equal(t, data, pp.Data)
+	assert.Equal(t, uint16(4), pp.Index)
+	assert.Equal(t, uint8(PacketTypeBroadcast), pp.PacketType)
+	assert.Equal(t, uint32(128), pp.Len)
+}
+
+// shortReader returns fewer bytes than requested for reads of size bytes
+type shortReader struct {
+	*MemBuffer
+	size int
+}
+
+func (r shortReader) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == r.size {
+		p = p[:len(p)-1]
+	}
+	return r.MemBuffer.ReadAt(p, off)
+}
+
+func TestReadPacketShortRead(t *testing.T) {
+	pcap := NewMemory()
+	_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 32, Data: make([]byte, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := pcap.Bytes()
+
+	rd, err := NewReader(shortReader{NewMemBuffer(raw), 32}, int64(len(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rd.ReadPacket(new(Packet))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, ErrRead, rd.LastError())
+}
+
+func TestReadPacketTruncatedPayload(t *testing.T) {
+	pcap := NewMemory()
+	for i := 0; i < 2; i++ {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 32, Data: make([]byte, 32)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the file is cut in the middle of the payload of the second packet
+	raw := pcap.Bytes()
+	raw = raw[:len(raw)-16]
+
+	for _, rd := range []ReaderWriterCloser{NewMemBuffer(raw), eofReader{NewMemBuffer(raw)}} {
+		rd, err := NewReader(rd, int64(len(raw)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := new(Packet)
+		_, err = rd.ReadPacket(p)
+		assert.NoError(t, err)
+		_, err = rd.ReadPacket(p)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		assert.Equal(t, ErrRead, rd.LastError())
+	}
+}
+
+// eofReader returns io.EOF with reads ending at the end of the buffer
+type eofReader struct {
+	*MemBuffer
+}
+
+func (r eofReader) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.MemBuffer.ReadAt(p, off)
+	if err == nil && off+int64(n) == int64(len(r.Bytes())) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func TestReadPacketHeaderAtEOF(t *testing.T) {
+	pcap := NewMemory()
+	_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 0, Data: []byte{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := pcap.Bytes()
+
+	// full header of an empty packet at the end of the file
+	rd, err := NewReader(eofReader{NewMemBuffer(raw)}, int64(len(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := new(Packet)
+	_, err = rd.ReadPacket(p)
+	assert.NoError(t, err)
+	assert.Empty(t, p.Data)
+
+	// clean end of the file
+	_, err = rd.ReadPacket(p)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, ErrNoMorePacket, rd.LastError())
+
+	// partial header
+	truncated := raw[:len(raw)-createdPacketSize+4]
+	rd, err = OpenMemory(truncated)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, rd.Next())
+	_, err = rd.ReadPacket(p)
+	assert.ErrorIs(t, err, ErrTruncatedPacket)
+	var perr *ParseError
+	if assert.ErrorAs(t, err, &perr) {
+		assert.Equal(t, int64(createdHeaderSize), perr.Offset)
+	}
+	assert.Equal(t, ErrRead, rd.LastError())
+}
+
+func TestPeekN(t *testing.T) {
+	pcap := createSequence(t, 5)
+	defer pcap.Close()
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+
+	peeked, err := pcap.PeekN(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, peeked, 3)
+	assert.Equal(t, 1, pcap.Len())
+	for _, want := range peeked {
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, want, *p)
+	}
+	assert.Equal(t, uint16(3), peeked[2].Index)
+
+	peeked, err = pcap.PeekN(3)
+	assert.NoError(t, err)
+	assert.Len(t, peeked, 1)
+	assert.True(t, pcap.Next())
+}
+
+func TestSync(t *testing.T) {
+	o := newOptions([]Option{WithSync()})
+	assert.NotZero(t, o.openFlags()&os.O_SYNC)
+	o = newOptions(nil)
+	assert.Zero(t, o.openFlags()&os.O_SYNC)
+
+	pcap, err := Create(filepath.Join(t.TempDir(), "sync"), WithSync())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	_, err = pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{1}})
+	assert.NoError(t, err)
+
+	assert.Equal(t, os.O_SYNC, openFlagsOf(t, pcap.rd.(*os.File))&os.O_SYNC)
+}
+
+// openFlagsOf returns the open flags of the descriptor of f,
+// skipping the test where they are not visible
+func openFlagsOf(t *testing.T, f *os.File) int {
+	// the open flags of the descriptor are only visible on Linux
+	info, err := os.ReadFile(fmt.Sprintf("/proc/self/fdinfo/%d", f.Fd()))
+	if err != nil {
+		t.Skip("open flags are not available:", err)
+	}
+	var flags int
+	for _, line := range strings.Split(string(info), "\n") {
+		if v, ok := strings.CutPrefix(line, "flags:"); ok {
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 8, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			flags = int(n)
+		}
+	}
+	return flags
+}
+
+func TestCreateTruncates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "existing")
+	if err := os.WriteFile(path, bytes.Repeat([]byte{0xff}, 1024), 0644); err != nil {
+		t.Fatal(err)
+	}
+	pcap, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, pcap.IsEmpty())
+	assert.NoError(t, pcap.Close())
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, raw, createdHeaderSize)
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	assert.False(t, pcap.Next())
+}
+
+func TestFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions are not supported on Windows")
+	}
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		opts []Option
+		mode os.FileMode
+	}{
+		{nil, 0644},
+		{[]Option{WithFileMode(0600)}, 0600},
+		{[]Option{WithFileMode(0640)}, 0640},
+	} {
+		for name, create := range map[string]func(string, ...Option) (*PCAP, error){
+			"create":    Create,
+			"exclusive": CreateExclusive,
+			"atomic":    CreateAtomic,
+		} {
+			path := filepath.Join(dir, name+tc.mode.String())
+			pcap, err := create(path, tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pcap.Close()
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, umasked(t, tc.mode), info.Mode().Perm(), name)
+		}
+	}
+}
+
+// umasked returns the permission bits a new file created with mode gets
+// once the process umask has been applied.
+func umasked(t *testing.T, mode os.FileMode) os.FileMode {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "umask")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.Mode().Perm()
+}
+
+func TestCreateExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exclusive")
+	pcap, err := CreateExclusive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{1}})
+	assert.NoError(t, err)
+	assert.NoError(t, pcap.Close())
+
+	_, err = CreateExclusive(path)
+	assert.ErrorIs(t, err, os.ErrExist)
+
+	// the existing file is left intact
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	packets, err := pcap.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 1)
+}
+
+func TestReadPacketParseErrorOffset(t *testing.T) {
+	pcap := createSequence(t, 3)
+	defer pcap.Close()
+	raw := make([]byte, pcap.Size())
+	if _, err := pcap.rd.ReadAt(raw, 0); err != nil {
+		t.Fatal(err)
+	}
+	const second = createdHeaderSize + createdPacketSize + 4
+	raw[second+1] = 0xff // packet type
+	binary.LittleEndian.PutUint32(raw[second+createdPacketSize+4+6:], MaxSnapLength+1)
+
+	rd, err := OpenMemory(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := new(Packet)
+	if _, err := rd.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	_, err = rd.ReadPacket(p)
+	var perr *ParseError
+	if assert.ErrorAs(t, err, &perr) {
+		assert.Equal(t, int64(second+1), perr.Offset)
+	}
+
+	// skip the payload of the broken packet
+	if err := rd.SetOffset(second + createdPacketSize + 4); err != nil {
+		t.Fatal(err)
+	}
+	_, err = rd.ReadPacket(p)
+	if assert.ErrorAs(t, err, &perr) {
+		assert.Equal(t, int64(second+createdPacketSize+4+6), perr.Offset)
+	}
+}
+
+func TestReadConcatenated(t *testing.T) {
+	dir := t.TempDir()
+	var raw []byte
+	for i, name := range []string{"first", "second"} {
+		path := filepath.Join(dir, name)
+		pcap, err := Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < 3; j++ {
+			_, err := pcap.WritePacket(Packet{
+				Index:      uint16(i*3 + j),
+				PacketType: PacketTypeUnicast,
+				Timestamp:  uint32(time.Now().UnixNano()),
+				Len:        4,
+				Data:       []byte{1, 2, 3, 4},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		pcap.Close()
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw = append(raw, b...)
+	}
+
+	path := filepath.Join(dir, "concatenated")
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	pcap, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+
+	var indexes []uint16
+	for pcap.Next() {
+		p := new(Packet)
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		indexes = append(indexes, p.Index)
+	}
+	assert.Equal(t, []uint16{0, 1, 2, 3, 4, 5}, indexes)
 }
 
 func BenchmarkReadPacket(b *testing.B) {
-	pcap, err := Create("0pcap")
+	pcap, err := Create(filepath.Join(b.TempDir(), "read"))
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -70,11 +437,15 @@ func BenchmarkReadPacket(b *testing.B) {
 			b.Fatal(err, n)
 		}
 	}
+	rd, err := pcap.FinishWriting()
+	if err != nil {
+		b.Fatal(err)
+	}
 	b.ResetTimer()
 
 	p := new(Packet)
 	for i := 0; i < b.N; i++ {
-		n, err := pcap.ReadPacket(p)
+		n, err := rd.ReadPacket(p)
 		if err != nil {
 			b.Fatal(err, n)
 		}
@@ -82,7 +453,7 @@ func BenchmarkReadPacket(b *testing.B) {
 }
 
 func BenchmarkWritePacket(b *testing.B) {
-	pcap, err := Create("0pcap")
+	pcap, err := Create(filepath.Join(b.TempDir(), "write"))
 	if err != nil {
 		b.Fatal(err)
 	}
@@ -106,3 +477,739 @@ func BenchmarkWritePacket(b *testing.B) {
 		}
 	}
 }
+
+func TestFinishWriting(t *testing.T) {
+	pcap, err := Create(filepath.Join(t.TempDir(), "finish"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+
+	want := [][]byte{{0x1}, {0x2, 0x3}, {0x4, 0x5, 0x6}}
+	for _, data := range want {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: uint32(len(data)), Data: data})
+		assert.NoError(t, err)
+	}
+
+	rd, err := pcap.FinishWriting()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := rd.ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, len(want), len(got))
+	for i := range want {
+		assert.Equal(t, want[i], got[i].Data)
+	}
+	assert.NoError(t, rd.Close())
+
+	_, err = rd.FinishWriting()
+	assert.ErrorIs(t, err, ErrUnsupportedOperation)
+}
+
+func TestOpenRW(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rw")
+	packets := []Packet{
+		{Index: 1, PacketType: PacketTypeUnicast, Timestamp: 1, Len: 2, Data: []byte{1, 2}},
+		{Index: 2, PacketType: PacketTypeUnicast, Timestamp: 2, Len: 2, Data: []byte{3, 4}},
+	}
+	if err := WriteFile(path, LinkTypeEthernet2, MaxSnapLength, packets); err != nil {
+		t.Fatal(err)
+	}
+
+	pcap, err := OpenRW(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, packets[0], *p)
+
+	appended := Packet{Index: 3, PacketType: PacketTypeBroadcast, Timestamp: 3, Len: 1, Data: []byte{5}}
+	if _, err := pcap.WritePacket(appended); err != nil {
+		t.Fatal(err)
+	}
+	var got []Packet
+	for pcap.Next() {
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, *p)
+	}
+	assert.Equal(t, []Packet{packets[1], appended}, got)
+	assert.NoError(t, pcap.Close())
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	got, err = pcap.ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, append(packets, appended), got)
+}
+
+func TestSetLinkTypePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "link")
+	pcap, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pcap.SetLinkType(LinkTypeEthernet80211); err != nil {
+		t.Fatal(err)
+	}
+	pcap.Close()
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	assert.Equal(t, LinkTypeEthernet80211, pcap.LinkType())
+}
+
+func TestSetLinkTypeInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "link")
+	pcap, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, pcap.SetLinkType(LinkTypeFDDI))
+	assert.Equal(t, LinkTypeEthernet2, pcap.LinkType())
+	pcap.Close()
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	assert.Equal(t, LinkTypeEthernet2, pcap.LinkType())
+
+	// the link type is restored if the header cannot be rewritten
+	w, err := NewStreamWriter(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, w.SetLinkType(LinkTypeEthernet80211))
+	assert.Equal(t, LinkTypeEthernet2, w.LinkType())
+}
+
+func TestPacketFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flags")
+	pcap, err := Create(path, WithPacketFlags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := []uint16{0x1, 0x8000, 0xbeef}
+	for _, f := range flags {
+		_, err := pcap.WritePacket(Packet{
+			Index:      1,
+			PacketType: PacketTypeUnicast,
+			Timestamp:  uint32(time.Now().UnixNano()),
+			Len:        3,
+			Data:       []byte{1, 2, 3},
+			Flags:      f,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	pcap.Close()
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	for _, f := range flags {
+		p := new(Packet)
+		n, err := pcap.ReadPacket(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, createdPacketSize+packetFlagsSize+3, n)
+		assert.Equal(t, f, p.Flags)
+		assert.Equal(t, []byte{1, 2, 3}, p.Data)
+	}
+	assert.False(t, pcap.Next())
+}
+
+func TestPacketFlagsVersion10(t *testing.T) {
+	// version 1.0 file has no header flags, so packets have no flags field
+	b := make([]byte, minFileSize+minPacketSize+1)
+	binary.LittleEndian.PutUint16(b, lpcapmx)
+	binary.LittleEndian.PutUint16(b[2:], MajorVer)
+	binary.LittleEndian.PutUint32(b[6:], MaxSnapLength)
+	binary.LittleEndian.PutUint32(b[10:], uint32(LinkTypeEthernet2))
+	b[minFileSize+1] = PacketTypeUnicast
+	binary.LittleEndian.PutUint32(b[minFileSize+6:], 1)
+	b[minFileSize+minPacketSize] = 0xff
+
+	pcap, err := OpenMemory(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := new(Packet)
+	n, err := pcap.ReadPacket(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, minPacketSize+1, n)
+	assert.Equal(t, uint16(0), p.Flags)
+	assert.Equal(t, []byte{0xff}, p.Data)
+	assert.False(t, pcap.Next())
+}
+
+func TestDataOffset(t *testing.T) {
+	b := make([]byte, minFileSize)
+	binary.LittleEndian.PutUint16(b, lpcapmx)
+	binary.LittleEndian.PutUint16(b[2:], MajorVer)
+	binary.LittleEndian.PutUint32(b[6:], MaxSnapLength)
+	binary.LittleEndian.PutUint32(b[10:], uint32(LinkTypeEthernet2))
+	pcap, err := OpenMemory(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(minFileSize), pcap.DataOffset())
+
+	pcap = NewMemory()
+	assert.Equal(t, int64(createdHeaderSize), pcap.DataOffset())
+	assert.NoError(t, pcap.AddInterface(1, "eth0"))
+	assert.NoError(t, pcap.SetCaptureStart(time.Unix(1, 0)))
+	off := pcap.DataOffset()
+	assert.Greater(t, off, int64(createdHeaderSize))
+
+	pcap, err = OpenMemory(pcap.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, off, pcap.DataOffset())
+	assert.Equal(t, off, pcap.Tell())
+}
+
+func TestIsEmpty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty")
+	pcap, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, pcap.IsEmpty())
+	pcap.Close()
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	assert.True(t, pcap.IsEmpty())
+	assert.False(t, pcap.Next())
+}
+
+func TestSeekOffset(t *testing.T) {
+	pcap, err := Create(filepath.Join(t.TempDir(), "seek"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	for i := 0; i < 3; i++ {
+		_, err := pcap.WritePacket(Packet{
+			Index:      uint16(i),
+			PacketType: PacketTypeUnicast,
+			Timestamp:  uint32(time.Now().UnixNano()),
+			Len:        6,
+			Data:       make([]byte, 6),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	const size = createdPacketSize + 6
+
+	off, err := pcap.SeekOffset(createdHeaderSize+size, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(createdHeaderSize+size), off)
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint16(1), p.Index)
+
+	off, err = pcap.SeekOffset(-2*size, io.SeekCurrent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(createdHeaderSize), off)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint16(0), p.Index)
+
+	off, err = pcap.SeekOffset(-size, io.SeekEnd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(createdHeaderSize+2*size), off)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint16(2), p.Index)
+	assert.False(t, pcap.Next())
+
+	_, err = pcap.SeekOffset(0, io.SeekStart)
+	assert.Error(t, err)
+	_, err = pcap.SeekOffset(1, io.SeekEnd)
+	assert.Error(t, err)
+}
+
+func TestRemaining(t *testing.T) {
+	pcap := createSequence(t, 3)
+	defer pcap.Close()
+	const size = createdPacketSize + 4
+	assert.Equal(t, int64(createdHeaderSize+3*size), pcap.Size())
+
+	p := new(Packet)
+	for i := 3; i > 0; i-- {
+		assert.Equal(t, int64(i*size), pcap.Remaining())
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Zero(t, pcap.Remaining())
+	assert.False(t, pcap.Next())
+}
+
+func TestTellSetOffset(t *testing.T) {
+	pcap := createSequence(t, 6)
+	defer pcap.Close()
+	p := new(Packet)
+	for i := 0; i < 2; i++ {
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mark := pcap.Tell()
+	assert.Equal(t, int64(createdHeaderSize+2*(createdPacketSize+4)), mark)
+
+	var first []uint16
+	for i := 0; i < 2; i++ {
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		first = append(first, p.Index)
+	}
+	if err := pcap.SetOffset(mark); err != nil {
+		t.Fatal(err)
+	}
+	var again []uint16
+	for i := 0; i < 2; i++ {
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		again = append(again, p.Index)
+	}
+	assert.Equal(t, []uint16{2, 3}, first)
+	assert.Equal(t, first, again)
+
+	assert.Error(t, pcap.SetOffset(createdHeaderSize-1))
+	assert.Error(t, pcap.SetOffset(pcap.fsize+1))
+	assert.Equal(t, mark+2*(createdPacketSize+4), pcap.Tell())
+}
+
+func TestUnsupportedOperation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "readonly")
+	if err := WriteFile(path, LinkTypeEthernet2, MaxSnapLength, nil); err != nil {
+		t.Fatal(err)
+	}
+	file, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	memory, err := OpenMemory(NewMemory().Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeker, err := NewSeekReader(readSeeker{bytes.NewReader(NewMemory().Bytes())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer := NewMemory()
+
+	p := Packet{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{1}}
+	for _, pcap := range []*PCAP{file, memory, seeker, writer.Clone()} {
+		_, err := pcap.WritePacket(p)
+		assert.ErrorIs(t, err, ErrUnsupportedOperation)
+		assert.Contains(t, err.Error(), "WritePacket")
+		assert.ErrorIs(t, pcap.AddInterface(1, "eth1"), ErrUnsupportedOperation)
+		assert.ErrorIs(t, pcap.SetCaptureStart(time.Now()), ErrUnsupportedOperation)
+		_, err = pcap.DrainTo(writer)
+		assert.ErrorIs(t, err, ErrUnsupportedOperation)
+	}
+	assert.True(t, writer.IsEmpty())
+	_, err = seeker.rd.Write([]byte{1})
+	assert.ErrorIs(t, err, ErrUnsupportedOperation)
+}
+
+func TestCloseWithoutPackets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "close")
+	pcap, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, pcap.Close())
+	assert.Error(t, pcap.Close())
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, pcap.IsEmpty())
+	assert.Equal(t, LinkTypeEthernet2, pcap.LinkType())
+	assert.NoError(t, pcap.Close())
+}
+
+func TestZeroTimestamp(t *testing.T) {
+	pcap, err := Create(filepath.Join(t.TempDir(), "zero"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	_, err = pcap.WritePacket(Packet{
+		Index:      1,
+		PacketType: PacketTypeBroadcast,
+		Timestamp:  0,
+		Len:        2,
+		Data:       []byte{1, 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint32(0), p.Timestamp)
+	assert.Equal(t, []byte{1, 2}, p.Data)
+}
+
+func TestAutoTimestamp(t *testing.T) {
+	start := time.Unix(0, 1000)
+	step := 250 * time.Microsecond
+	pcap := NewMemory(WithAutoTimestamp(start, step))
+	for i := 0; i < 5; i++ {
+		_, err := pcap.WritePacket(Packet{
+			PacketType: PacketTypeUnicast,
+			Timestamp:  12345,
+			Len:        1,
+			Data:       []byte{0},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := new(Packet)
+	for i := 0; i < 5; i++ {
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, uint32(start.Add(step*time.Duration(i)).UnixNano()), p.Timestamp)
+	}
+}
+
+// size of the file header written by Create
+const createdHeaderSize = extFileSize + headerChecksumSize
+
+// size of the packet header written by Create, without packet flags
+const createdPacketSize = minPacketSize + wideIndexSize
+
+func TestValidateWrite(t *testing.T) {
+	pcap := NewMemory(WithSnapLength(128))
+	packets := []Packet{
+		{PacketType: PacketTypeUnicast, Len: 16, Data: make([]byte, 16)},
+		{PacketType: PacketTypeUnicast, Len: 100, Data: make([]byte, 100)},
+		{PacketType: PacketTypeUnicast, Len: 256, Data: make([]byte, 256)},
+		{PacketType: PacketTypeUnicast, Len: 16, Data: make([]byte, 16)},
+	}
+
+	err := pcap.ValidateWrite(packets)
+	var verr *ValidationError
+	if assert.ErrorAs(t, err, &verr) {
+		assert.Equal(t, 2, verr.Index)
+	}
+	assert.True(t, pcap.IsEmpty())
+
+	assert.NoError(t, pcap.ValidateWrite(packets[:2]))
+	assert.Error(t, pcap.ValidateWrite([]Packet{{PacketType: PacketTypeUnicast, Len: 4, Data: make([]byte, 2)}}))
+	assert.Error(t, pcap.ValidateWrite([]Packet{{PacketType: 3}}))
+
+	for i, p := range packets {
+		err := pcap.ValidatePacket(p)
+		if i == 2 {
+			assert.Error(t, err)
+		} else {
+			assert.NoError(t, err)
+		}
+	}
+	assert.True(t, pcap.IsEmpty())
+}
+
+func TestAdaptiveSnapLength(t *testing.T) {
+	pcap := NewMemory(WithSnapLength(64), WithAdaptiveSnapLength())
+	for _, size := range []int{16, 100, 50, 1000} {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: uint32(size), Data: make([]byte, size)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: MaxSnapLength, Data: make([]byte, MaxSnapLength)})
+	assert.Error(t, err)
+	assert.NoError(t, pcap.ValidateWrite([]Packet{{PacketType: PacketTypeUnicast, Len: 2000, Data: make([]byte, 2000)}}))
+
+	rd, err := OpenMemory(pcap.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint32(1000+minPacketSize), rd.h.snapLen)
+	packets, err := rd.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 4)
+}
+
+func TestCopyData(t *testing.T) {
+	pcap := createSequence(t, 2)
+	defer pcap.Close()
+
+	first, second := new(Packet), new(Packet)
+	if _, err := pcap.ReadPacket(first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pcap.ReadPacket(second); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []byte{0, 0, 0, 0}, first.Data)
+	assert.Equal(t, []byte{1, 0, 0, 0}, second.Data)
+	assert.NotSame(t, &first.Data[0], &second.Data[0])
+}
+
+func TestWithoutPool(t *testing.T) {
+	w := NewMemory(WithoutPool())
+	for i := 0; i < 4; i++ {
+		_, err := w.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 2, Data: []byte{byte(i), byte(i)}})
+		assert.NoError(t, err)
+	}
+
+	pcap, err := OpenMemory(w.Bytes(), WithCopyData(false), WithoutPool())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var packets []*Packet
+	for pcap.Next() {
+		p := new(Packet)
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		packets = append(packets, p)
+		// reads and writes of other captures must not reuse the buffers
+		_, err = NewMemory().WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 2, Data: []byte{0xff, 0xff}})
+		assert.NoError(t, err)
+	}
+	for i, p := range packets {
+		assert.Equal(t, []byte{byte(i), byte(i)}, p.Data)
+	}
+	assert.NotSame(t, &packets[0].Data[0], &packets[1].Data[0])
+}
+
+func TestReadPacketCopy(t *testing.T) {
+	w := NewMemory()
+	for _, data := range [][]byte{{1, 2}, {3, 4}} {
+		_, err := w.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 2, Data: data})
+		assert.NoError(t, err)
+	}
+
+	pcap, err := OpenMemory(w.Bytes(), WithCopyData(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := new(Packet), new(Packet)
+	if _, err := pcap.ReadPacketCopy(first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pcap.ReadPacketCopy(second); err != nil {
+		t.Fatal(err)
+	}
+	// reuse the pool for following reads and writes
+	pcap.SetOffset(pcap.DataOffset())
+	for pcap.Next() {
+		_, err := pcap.ReadPacket(new(Packet))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, []byte{1, 2}, first.Data)
+	assert.Equal(t, []byte{3, 4}, second.Data)
+}
+
+func TestMaxFileSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "full")
+	max := int64(createdHeaderSize + 3*(createdPacketSize+4) + footerSize)
+	pcap, err := Create(path, WithMaxFileSize(max), WithSummaryFooter())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written int
+	for {
+		_, err = pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 4, Data: []byte{1, 2, 3, 4}})
+		if err != nil {
+			break
+		}
+		written++
+	}
+	assert.ErrorIs(t, err, ErrCaptureFull)
+	assert.Equal(t, ErrCaptureFull, pcap.LastError())
+	assert.Equal(t, 3, written)
+	assert.NoError(t, pcap.Close())
+
+	s, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, max, s.Size())
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	packets, err := pcap.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, written)
+}
+
+func TestMaxPackets(t *testing.T) {
+	w := NewMemory()
+	for i := 0; i < 10000; i++ {
+		_, err := w.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 0, Data: []byte{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pcap, err := OpenMemory(w.Bytes(), WithMaxPackets(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := pcap.ReadAll()
+	assert.ErrorIs(t, err, ErrTooManyPackets)
+	assert.Len(t, packets, 100)
+	assert.True(t, pcap.Next())
+
+	_, err = pcap.BuildIndex()
+	assert.ErrorIs(t, err, ErrTooManyPackets)
+
+	pcap, err = OpenMemory(w.Bytes(), WithMaxPackets(10000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err = pcap.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 10000)
+}
+
+func TestPayloadValidator(t *testing.T) {
+	w := NewMemory()
+	// frames end with the sum of the preceding bytes
+	frames := [][]byte{{1, 2, 3}, {4, 5, 0}, {6, 6}}
+	for _, data := range frames {
+		_, err := w.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: uint32(len(data)), Data: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pcap, err := OpenMemory(w.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	errSum := errors.New("sum mismatch")
+	pcap.SetPayloadValidator(func(p Packet) error {
+		var sum byte
+		for _, b := range p.Data[:len(p.Data)-1] {
+			sum += b
+		}
+		if sum != p.Data[len(p.Data)-1] {
+			return errSum
+		}
+		return nil
+	})
+
+	p := new(Packet)
+	_, err = pcap.ReadPacket(p)
+	assert.NoError(t, err)
+	_, err = pcap.ReadPacket(p)
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+	assert.ErrorIs(t, err, errSum)
+	var verr *ValidationError
+	if assert.ErrorAs(t, err, &verr) {
+		assert.Equal(t, 1, verr.Index)
+	}
+	// the rejected packet is consumed
+	_, err = pcap.ReadPacket(p)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{6, 6}, p.Data)
+
+	pcap.SetPayloadValidator(nil)
+	_, err = pcap.SeekOffset(pcap.DataOffset(), io.SeekStart)
+	assert.NoError(t, err)
+	packets, err := pcap.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 3)
+}
+
+func TestMaxPacketSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "max")
+	err := WriteFile(path, LinkTypeEthernet2, MaxSnapLength, []Packet{
+		{PacketType: PacketTypeUnicast, Len: 512, Data: make([]byte, 512)},
+		{PacketType: PacketTypeUnicast, Len: 4096, Data: make([]byte, 4096)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pcap, err := Open(path, WithMaxPacketSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+
+	p := new(Packet)
+	_, err = pcap.ReadPacket(p)
+	assert.NoError(t, err)
+	_, err = pcap.ReadPacket(p)
+	var perr *ParseError
+	if assert.ErrorAs(t, err, &perr) {
+		assert.Equal(t, int64(createdHeaderSize+createdPacketSize+512+6), perr.Offset)
+	}
+	assert.Equal(t, ErrSizeOverflow, pcap.LastError())
+}
+
+func FuzzReadPacket(f *testing.F) {
+	for _, seed := range fuzzSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, b []byte) {
+		pcap, err := OpenMemory(b)
+		if err != nil {
+			return
+		}
+		p := new(Packet)
+		for pcap.Next() {
+			if _, err := pcap.ReadPacket(p); err != nil {
+				return
+			}
+			assert.Equal(t, int(p.Len), len(p.Data))
+		}
+	})
+}
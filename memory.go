@@ -0,0 +1,146 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// MemBuffer is a growable in-memory file implementing ReaderWriterCloser.
+// Write appends to the end, Read consumes from its own cursor and
+// ReadAt/WriteAt use explicit offsets. The zero value is an empty buffer
+// ready to use.
+type MemBuffer struct {
+	mx     sync.RWMutex
+	buf    []byte
+	off    int64 // read cursor of Read
+	closed bool
+}
+
+// NewMemBuffer returns a MemBuffer holding b, which is used without copying
+func NewMemBuffer(b []byte) *MemBuffer {
+	return &MemBuffer{buf: b}
+}
+
+func (m *MemBuffer) Read(p []byte) (int, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.closed {
+		return 0, os.ErrClosed
+	}
+	if m.off >= int64(len(m.buf)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.buf[m.off:])
+	m.off += int64(n)
+	return n, nil
+}
+
+func (m *MemBuffer) ReadAt(p []byte, off int64) (int, error) {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+	if m.closed {
+		return 0, os.ErrClosed
+	}
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(len(m.buf)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.buf[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (m *MemBuffer) Write(p []byte) (int, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.closed {
+		return 0, os.ErrClosed
+	}
+	m.buf = append(m.buf, p...)
+	return len(p), nil
+}
+
+func (m *MemBuffer) WriteAt(p []byte, off int64) (int, error) {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.closed {
+		return 0, os.ErrClosed
+	}
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if end := off + int64(len(p)); end > int64(len(m.buf)) {
+		m.buf = append(m.buf, make([]byte, end-int64(len(m.buf)))...)
+	}
+	return copy(m.buf[off:], p), nil
+}
+
+func (m *MemBuffer) Truncate(size int64) error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.closed {
+		return os.ErrClosed
+	}
+	if size < 0 || size > int64(len(m.buf)) {
+		return errors.New("invalid truncate size")
+	}
+	m.buf = m.buf[:size]
+	return nil
+}
+
+// Close makes further reads and writes fail, the content stays available
+func (m *MemBuffer) Close() error {
+	m.mx.Lock()
+	defer m.mx.Unlock()
+	if m.closed {
+		return os.ErrClosed
+	}
+	m.closed = true
+	return nil
+}
+
+// Bytes returns the content of the buffer
+func (m *MemBuffer) Bytes() []byte {
+	m.mx.RLock()
+	defer m.mx.RUnlock()
+	return m.buf
+}
+
+// NewMemory creates a PCAP backed by a growable in-memory buffer
+// instead of a file, with the file header already written. Writing to
+// memory never fails, so NewMemory panics only if the options are invalid,
+// use NewWriter with a MemBuffer to handle the error.
+func NewMemory(opts ...Option) *PCAP {
+	pcap, err := newWriter(&MemBuffer{}, newOptions(opts))
+	if err != nil {
+		panic(fmt.Errorf("lpcap: NewMemory: %w", err))
+	}
+	return pcap
+}
+
+// OpenMemory parses a serialized capture, such as returned by Bytes,
+// and returns the PCAP for reading it. The buffer is used without copying.
+func OpenMemory(b []byte, opts ...Option) (*PCAP, error) {
+	return newReader(NewMemBuffer(b), int64(len(b)), newOptions(opts))
+}
+
+// Bytes returns the serialized capture of a PCAP created by NewMemory or
+// OpenMemory, or nil if the PCAP is backed by a file. The returned slice
+// aliases the buffer and is valid until the next write.
+func (pcap *PCAP) Bytes() []byte {
+	m, ok := pcap.rd.(*MemBuffer)
+	if !ok {
+		return nil
+	}
+	return m.Bytes()
+}
@@ -0,0 +1,149 @@
+package lpcap
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemory(t *testing.T) {
+	pcap := NewMemory(WithPacketFlags())
+	for i := 0; i < 3; i++ {
+		_, err := pcap.WritePacket(Packet{
+			Index:      uint16(i),
+			PacketType: PacketTypeUnicast,
+			Timestamp:  uint32(i + 1),
+			Len:        3,
+			Data:       []byte{byte(i), 2, 3},
+			Flags:      uint16(i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := new(Packet)
+	for i := 0; pcap.Next(); i++ {
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, uint16(i), p.Index)
+		assert.Equal(t, []byte{byte(i), 2, 3}, p.Data)
+	}
+	assert.Equal(t, 3, pcap.Len())
+	raw := pcap.Bytes()
+	assert.Len(t, raw, createdHeaderSize+3*(createdPacketSize+packetFlagsSize+3))
+	assert.NoError(t, pcap.Close())
+
+	// reopen the serialized capture
+	pcap, err := OpenMemory(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	for i := 0; pcap.Next(); i++ {
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, uint16(i), p.Flags)
+		assert.Equal(t, uint32(i+1), p.Timestamp)
+	}
+	assert.Equal(t, 3, pcap.Len())
+
+	_, err = OpenMemory(raw[:10])
+	assert.Error(t, err)
+}
+
+func TestMemoryInterleaved(t *testing.T) {
+	pcap := NewMemory()
+	defer pcap.Close()
+	assert.True(t, pcap.IsEmpty())
+	assert.False(t, pcap.Next())
+
+	p := new(Packet)
+	for i := 0; i < 3; i++ {
+		_, err := pcap.WritePacket(Packet{
+			Index:      uint16(i),
+			PacketType: PacketTypeMulticast,
+			Len:        1,
+			Data:       []byte{byte(i)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, pcap.Next())
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, uint16(i), p.Index)
+		assert.Equal(t, []byte{byte(i)}, p.Data)
+		assert.False(t, pcap.Next())
+	}
+
+	assert.PanicsWithError(t, "lpcap: NewMemory: snap length must be between packet header length and MaxSnapLength", func() {
+		NewMemory(WithSnapLength(0))
+	})
+	_, err := NewWriter(&MemBuffer{}, WithSnapLength(0))
+	assert.Error(t, err)
+}
+
+func TestMemBuffer(t *testing.T) {
+	m := new(MemBuffer)
+	_, err := m.Write([]byte{1, 2, 3})
+	assert.NoError(t, err)
+
+	b := make([]byte, 2)
+	n, err := m.ReadAt(b, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{2, 3}, b[:n])
+
+	// ReadAt does not move the Read cursor
+	n, err = m.Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{1, 2}, b[:n])
+
+	_, err = m.Write([]byte{4, 5})
+	assert.NoError(t, err)
+	n, err = m.ReadAt(b, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{4, 5}, b[:n])
+	n, err = m.ReadAt(b, 4)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, []byte{5}, b[:n])
+
+	n, err = m.Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{3, 4}, b[:n])
+	n, err = m.Read(b)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{5}, b[:n])
+	_, err = m.Read(b)
+	assert.Equal(t, io.EOF, err)
+
+	assert.NoError(t, m.Close())
+	_, err = m.Write(b)
+	assert.Error(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4, 5}, m.Bytes())
+}
+
+func TestMemBufferWriterReader(t *testing.T) {
+	m := new(MemBuffer)
+	w, err := NewWriter(m, WithLinkType(LinkTypeEthernet80211))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 2, Data: []byte{1, 2}})
+	assert.NoError(t, err)
+
+	r, err := NewReader(NewMemBuffer(m.Bytes()), int64(len(m.Bytes())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, LinkTypeEthernet80211, r.LinkType())
+	packets, err := r.ReadAll()
+	assert.NoError(t, err)
+	if assert.Len(t, packets, 1) {
+		assert.Equal(t, []byte{1, 2}, packets[0].Data)
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// Metrics is a snapshot of the read and write counters of a PCAP
+type Metrics struct {
+	PacketsRead    uint64 // packets successfully read
+	PacketsWritten uint64 // packets successfully written
+	BytesRead      uint64 // bytes of read packets, including packet headers
+	BytesWritten   uint64 // bytes of written packets, including packet headers
+	ReadErrors     uint64 // failed reads, except reaching the end of the file
+	WriteErrors    uint64 // failed writes, including rejected packets
+	OversizeDrops  uint64 // packets rejected by the snap length or maximum packet size
+}
+
+// metrics holds the counters updated atomically by reads and writes
+type metrics struct {
+	packetsRead    uint64
+	packetsWritten uint64
+	bytesRead      uint64
+	bytesWritten   uint64
+	readErrors     uint64
+	writeErrors    uint64
+	oversizeDrops  uint64
+}
+
+// Observer is notified of reads and writes of packets, for example to
+// export metrics to a monitoring system. Methods are called synchronously
+// by ReadPacket and WritePacket, so they must be fast, and concurrently
+// if the PCAP is used from several goroutines.
+type Observer interface {
+	// OnRead is called after a packet of n bytes, including the packet
+	// header, was read
+	OnRead(n int)
+	// OnWrite is called after a packet of n bytes, including the packet
+	// header, was written
+	OnWrite(n int)
+	// OnError is called after a read or write failed, except reads
+	// reaching the end of the file
+	OnError(err error)
+}
+
+// recordRead updates metrics and notifies the observer of a read
+func (pcap *PCAP) recordRead(n int, err error) {
+	pcap.metrics.read(n, err)
+	o := pcap.opts.observer
+	switch {
+	case o == nil:
+	case err == nil:
+		o.OnRead(n)
+	case err != io.EOF:
+		o.OnError(err)
+	}
+}
+
+// recordWrite updates metrics and notifies the observer of a write
+func (pcap *PCAP) recordWrite(n int, err error) {
+	pcap.metrics.write(n, err)
+	o := pcap.opts.observer
+	switch {
+	case o == nil:
+	case err == nil:
+		o.OnWrite(n)
+	default:
+		o.OnError(err)
+	}
+}
+
+func (m *metrics) read(n int, err error) {
+	switch {
+	case err == nil:
+		atomic.AddUint64(&m.packetsRead, 1)
+		atomic.AddUint64(&m.bytesRead, uint64(n))
+	case err != io.EOF:
+		atomic.AddUint64(&m.readErrors, 1)
+	}
+}
+
+func (m *metrics) write(n int, err error) {
+	if err != nil {
+		atomic.AddUint64(&m.writeErrors, 1)
+		return
+	}
+	atomic.AddUint64(&m.packetsWritten, 1)
+	atomic.AddUint64(&m.bytesWritten, uint64(n))
+}
+
+// Metrics returns the current values of the counters of pcap. Each counter
+// is loaded atomically, but the snapshot as a whole is not, since the
+// counters are updated without locking.
+func (pcap *PCAP) Metrics() Metrics {
+	m := &pcap.metrics
+	return Metrics{
+		PacketsRead:    atomic.LoadUint64(&m.packetsRead),
+		PacketsWritten: atomic.LoadUint64(&m.packetsWritten),
+		BytesRead:      atomic.LoadUint64(&m.bytesRead),
+		BytesWritten:   atomic.LoadUint64(&m.bytesWritten),
+		ReadErrors:     atomic.LoadUint64(&m.readErrors),
+		WriteErrors:    atomic.LoadUint64(&m.writeErrors),
+		OversizeDrops:  atomic.LoadUint64(&m.oversizeDrops),
+	}
+}
+
+// OversizeDrops returns the count of packets rejected by WritePacket for
+// exceeding the snap length, or by ReadPacket for exceeding the maximum
+// packet size, see WithMaxPacketSize.
+func (pcap *PCAP) OversizeDrops() int {
+	return int(atomic.LoadUint64(&pcap.metrics.oversizeDrops))
+}
+
+// Counters is a snapshot of CounterObserver
+type Counters struct {
+	PacketsRead    uint64
+	BytesRead      uint64
+	PacketsWritten uint64
+	BytesWritten   uint64
+	Errors         uint64
+}
+
+// CounterObserver is an Observer counting packets, bytes and errors with
+// atomic counters. It can be shared by several PCAPs.
+type CounterObserver struct {
+	packetsRead    uint64
+	bytesRead      uint64
+	packetsWritten uint64
+	bytesWritten   uint64
+	errors         uint64
+}
+
+func (c *CounterObserver) OnRead(n int) {
+	atomic.AddUint64(&c.packetsRead, 1)
+	atomic.AddUint64(&c.bytesRead, uint64(n))
+}
+
+func (c *CounterObserver) OnWrite(n int) {
+	atomic.AddUint64(&c.packetsWritten, 1)
+	atomic.AddUint64(&c.bytesWritten, uint64(n))
+}
+
+func (c *CounterObserver) OnError(err error) {
+	atomic.AddUint64(&c.errors, 1)
+}
+
+// Snapshot returns the current values of the counters
+func (c *CounterObserver) Snapshot() Counters {
+	return Counters{
+		PacketsRead:    atomic.LoadUint64(&c.packetsRead),
+		BytesRead:      atomic.LoadUint64(&c.bytesRead),
+		PacketsWritten: atomic.LoadUint64(&c.packetsWritten),
+		BytesWritten:   atomic.LoadUint64(&c.bytesWritten),
+		Errors:         atomic.LoadUint64(&c.errors),
+	}
+}
@@ -0,0 +1,82 @@
+package lpcap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	pcap := NewMemory(WithSnapLength(64))
+	for i := 0; i < 3; i++ {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 4, Data: make([]byte, 4)})
+		assert.NoError(t, err)
+	}
+	_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 128, Data: make([]byte, 128)})
+	assert.Error(t, err)
+	_, err = pcap.WritePacket(Packet{PacketType: 0, Len: 1, Data: make([]byte, 1)})
+	assert.Error(t, err)
+
+	size := uint64(createdPacketSize + 4)
+	assert.Equal(t, Metrics{
+		PacketsWritten: 3,
+		BytesWritten:   3 * size,
+		WriteErrors:    2,
+		OversizeDrops:  1,
+	}, pcap.Metrics())
+
+	rd, err := OpenMemory(pcap.Bytes(), WithMaxPacketSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rd.ReadPacket(new(Packet))
+	assert.Error(t, err)
+	assert.Equal(t, Metrics{ReadErrors: 1, OversizeDrops: 1}, rd.Metrics())
+
+	rd, err = OpenMemory(pcap.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rd.ReadAll()
+	assert.NoError(t, err)
+	assert.Equal(t, Metrics{
+		PacketsRead: 3,
+		BytesRead:   3 * size,
+	}, rd.Metrics())
+}
+
+func TestOversizeDrops(t *testing.T) {
+	pcap := NewMemory(WithSnapLength(32))
+	for _, size := range []int{8, 64, 16, 33, 1024} {
+		pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: uint32(size), Data: make([]byte, size)})
+	}
+	assert.Equal(t, 3, pcap.OversizeDrops())
+	assert.Equal(t, ErrSizeOverflow, pcap.LastError())
+}
+
+func TestObserver(t *testing.T) {
+	obs := new(CounterObserver)
+	pcap := NewMemory(WithSnapLength(64), WithObserver(obs))
+	for i := 0; i < 2; i++ {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 4, Data: make([]byte, 4)})
+		assert.NoError(t, err)
+	}
+	_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 128, Data: make([]byte, 128)})
+	assert.Error(t, err)
+
+	rd, err := OpenMemory(pcap.Bytes(), WithObserver(obs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = rd.ReadAll()
+	assert.NoError(t, err)
+
+	size := uint64(createdPacketSize + 4)
+	assert.Equal(t, Counters{
+		PacketsRead:    2,
+		BytesRead:      2 * size,
+		PacketsWritten: 2,
+		BytesWritten:   2 * size,
+		Errors:         1,
+	}, obs.Snapshot())
+}
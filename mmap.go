@@ -0,0 +1,282 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// logf reports misuse of borrowed packets found by the finalizer of a mapping
+var logf = log.Printf
+
+// mmapRegion is a read-only memory mapping of a file. Once payloads are
+// borrowed from it, the mapping is released by a finalizer after Close,
+// when no packet referencing it is reachable anymore.
+type mmapRegion struct {
+	mx       sync.Mutex
+	data     []byte
+	off      int64 // read cursor of Read
+	closed   bool
+	borrowed bool
+
+	done  *atomic.Bool // set by Close of the file, shared by all its mappings
+	stale atomic.Int64 // accesses to borrowed payloads after done was set
+}
+
+// newMmapRegion returns the region of mapped data, which is unmapped
+// by a finalizer unless the region is released before. done is set when
+// the file the data is mapped from is closed.
+func newMmapRegion(data []byte, done *atomic.Bool) *mmapRegion {
+	r := &mmapRegion{data: data, done: done}
+	runtime.SetFinalizer(r, (*mmapRegion).finalize)
+	return r
+}
+
+// finalize unmaps the region when neither the file nor any packet
+// borrowed from it is reachable, and logs the misuse seen until then
+func (r *mmapRegion) finalize() {
+	if !r.closed {
+		logf("lpcap: memory mapped capture was not closed")
+	}
+	if n := r.stale.Load(); n > 0 {
+		logf("lpcap: %d packets borrowed from a memory mapped capture were used after Close", n)
+	}
+	r.unmap()
+}
+
+// payload returns data borrowed from the region, or an error if the
+// file of the region was closed
+func (r *mmapRegion) payload(data []byte) ([]byte, error) {
+	if r.done.Load() {
+		r.stale.Add(1)
+		return nil, fmt.Errorf("packet borrowed from a memory mapped capture: %w", os.ErrClosed)
+	}
+	return data, nil
+}
+
+func (r *mmapRegion) Read(p []byte) (int, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if r.off >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[r.off:])
+	r.off += int64(n)
+	return n, nil
+}
+
+func (r *mmapRegion) ReadAt(p []byte, off int64) (int, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= int64(len(r.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// borrower slices payloads from a memory mapping without copying
+type borrower interface {
+	// borrow returns n bytes at off and the mapping they belong to
+	borrow(off int64, n int) ([]byte, *mmapRegion, error)
+}
+
+func (r *mmapRegion) borrow(off int64, n int) ([]byte, *mmapRegion, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.closed {
+		return nil, nil, os.ErrClosed
+	}
+	if off < 0 || off+int64(n) > int64(len(r.data)) {
+		return nil, nil, io.EOF
+	}
+	r.borrowed = true
+	return r.data[off : off+int64(n) : off+int64(n)], r, nil
+}
+
+func (r *mmapRegion) Write(p []byte) (int, error) {
+	return 0, errors.New("cannot write, file is memory mapped read-only")
+}
+
+// Close closes the file and releases the mapping
+func (r *mmapRegion) Close() error {
+	r.done.Store(true)
+	return r.release()
+}
+
+// release unmaps the region, unless payloads were borrowed from it
+func (r *mmapRegion) release() error {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if r.closed {
+		return os.ErrClosed
+	}
+	r.closed = true
+	if r.borrowed {
+		return nil
+	}
+	runtime.SetFinalizer(r, nil)
+	return r.unmap()
+}
+
+func (r *mmapRegion) unmap() error {
+	data := r.data
+	r.data = nil
+	return munmap(data)
+}
+
+// Payload returns Data of the packet, checking that it is still safe to
+// use. Data of a packet read with WithBorrowed is only valid until the
+// capture is closed, after that Payload returns an error wrapping
+// os.ErrClosed and the use is reported when the mapping is released.
+func (p Packet) Payload() ([]byte, error) {
+	if p.region == nil {
+		return p.Data, nil
+	}
+	return p.region.payload(p.Data)
+}
+
+// borrower returns the mapping payloads are borrowed from by
+// ReadPacket, or nil if payloads are copied
+func (pcap *PCAP) borrower() borrower {
+	if br, ok := pcap.rd.(borrower); ok && pcap.opts.borrowed {
+		return br
+	}
+	return nil
+}
+
+// OpenMmap opens a PCAP file for reading through a read-only memory
+// mapping, which avoids a system call for every read. With WithBorrowed,
+// payloads are not copied at all. The file must not be truncated while
+// it is mapped.
+func OpenMmap(path string, opts ...Option) (*PCAP, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	// the mapping stays valid after the file is closed
+	defer f.Close()
+
+	s, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if s.Size() < minFileSize {
+		return nil, errors.New("file length too small, cannot read file header")
+	}
+	data, err := mmap(f, int(s.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	r := newMmapRegion(data, new(atomic.Bool))
+	pcap, err := newReader(r, s.Size(), newOptions(opts))
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	return pcap, nil
+}
+
+// remapFile is a file opened for reading and appending, which reads
+// through a memory mapping. The mapping is replaced by a larger one when
+// a read reaches past its end, so the whole read comes from one mapping.
+// Payloads borrowed from a replaced mapping keep it alive.
+type remapFile struct {
+	*os.File
+	mx     sync.Mutex
+	region *mmapRegion
+	done   atomic.Bool // set by Close, checked by packets borrowed from any mapping
+}
+
+// mapping returns the mapping of the file covering end bytes if the
+// file is large enough, remapping the file when it has grown
+func (f *remapFile) mapping(end int64) (*mmapRegion, error) {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	if f.region != nil && end <= int64(len(f.region.data)) {
+		return f.region, nil
+	}
+	s, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if f.region != nil && s.Size() <= int64(len(f.region.data)) {
+		return f.region, nil
+	}
+	data, err := mmap(f.File, int(s.Size()))
+	if err != nil {
+		return nil, err
+	}
+	if f.region != nil {
+		f.region.release()
+	}
+	f.region = newMmapRegion(data, &f.done)
+	return f.region, nil
+}
+
+func (f *remapFile) ReadAt(p []byte, off int64) (int, error) {
+	r, err := f.mapping(off + int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	return r.ReadAt(p, off)
+}
+
+func (f *remapFile) borrow(off int64, n int) ([]byte, *mmapRegion, error) {
+	r, err := f.mapping(off + int64(n))
+	if err != nil {
+		return nil, nil, err
+	}
+	return r.borrow(off, n)
+}
+
+// Truncate drops the mapping before truncating the file, since
+// accessing mapped pages past the end of the file crashes the program
+func (f *remapFile) Truncate(size int64) error {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	if f.region != nil {
+		f.region.release()
+		f.region = nil
+	}
+	return f.File.Truncate(size)
+}
+
+func (f *remapFile) Close() error {
+	f.mx.Lock()
+	defer f.mx.Unlock()
+	f.done.Store(true)
+	if f.region != nil {
+		f.region.Close()
+		f.region = nil
+	}
+	return f.File.Close()
+}
+
+// OpenMmapRW opens an existing PCAP file for reading and appending like
+// OpenRW, but reads through a memory mapping like OpenMmap. The mapping
+// grows with the file as packets are appended.
+func OpenMmapRW(path string, opts ...Option) (*PCAP, error) {
+	return openRW(path, true, newOptions(opts))
+}
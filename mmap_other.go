@@ -0,0 +1,20 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package lpcap
+
+import (
+	"errors"
+	"os"
+)
+
+func mmap(f *os.File, size int) ([]byte, error) {
+	return nil, errors.New("memory mapping is not supported on this platform")
+}
+
+func munmap(b []byte) error {
+	return nil
+}
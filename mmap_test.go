@@ -0,0 +1,167 @@
+package lpcap
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openMmapSequence(t *testing.T, n int, opts ...Option) *PCAP {
+	path := filepath.Join(t.TempDir(), "mmap")
+	packets := make([]Packet, n)
+	for i := range packets {
+		packets[i] = Packet{Index: uint16(i), PacketType: PacketTypeUnicast, Len: 3, Data: []byte{byte(i), 1, 2}}
+	}
+	if err := WriteFile(path, LinkTypeEthernet2, MaxSnapLength, packets); err != nil {
+		t.Fatal(err)
+	}
+	pcap, err := OpenMmap(path, opts...)
+	if err != nil {
+		t.Skip("memory mapping is not available:", err)
+	}
+	return pcap
+}
+
+func TestOpenMmapBorrowed(t *testing.T) {
+	pcap := openMmapSequence(t, 3, WithBorrowed())
+	defer pcap.Close()
+	r := pcap.rd.(*mmapRegion)
+
+	for i := 0; pcap.Next(); i++ {
+		offset := pcap.offset
+		p := new(Packet)
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []byte{byte(i), 1, 2}, p.Data)
+		// data points into the mapping
+		assert.True(t, &r.data[offset+createdPacketSize] == &p.Data[0])
+	}
+	assert.Equal(t, 3, pcap.Len())
+}
+
+func TestOpenMmapReadPacketCopy(t *testing.T) {
+	pcap := openMmapSequence(t, 1, WithBorrowed())
+	defer pcap.Close()
+
+	p := new(Packet)
+	if _, err := pcap.ReadPacketCopy(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, p.region)
+	p.Data[0] = 0xff
+	assert.Equal(t, []byte{0xff, 1, 2}, p.Data)
+}
+
+func TestOpenMmapCopied(t *testing.T) {
+	pcap := openMmapSequence(t, 2)
+	packets, err := pcap.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 2)
+	assert.Nil(t, packets[0].region)
+
+	r := pcap.rd.(*mmapRegion)
+	assert.NoError(t, pcap.Close())
+	// nothing was borrowed, so the mapping is released on Close
+	assert.Nil(t, r.data)
+	_, err = r.ReadAt(make([]byte, 1), 0)
+	assert.Error(t, err)
+}
+
+func TestOpenMmapBorrowedAfterClose(t *testing.T) {
+	pcap := openMmapSequence(t, 1, WithBorrowed())
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	data, err := p.Payload()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0, 1, 2}, data)
+
+	assert.NoError(t, pcap.Close())
+	_, _, err = p.region.borrow(0, 1)
+	assert.Error(t, err)
+	_, err = p.Payload()
+	assert.ErrorIs(t, err, os.ErrClosed)
+	_, err = p.Payload()
+	assert.ErrorIs(t, err, os.ErrClosed)
+
+	// the finalizer reports the use after Close when it releases the mapping
+	var logged []string
+	logf = func(format string, v ...any) { logged = append(logged, fmt.Sprintf(format, v...)) }
+	defer func() { logf = log.Printf }()
+	r := p.region
+	runtime.SetFinalizer(r, nil)
+	r.finalize()
+	assert.Equal(t, []string{"lpcap: 2 packets borrowed from a memory mapped capture were used after Close"}, logged)
+	assert.Nil(t, r.data)
+}
+
+func TestOpenMmapNotClosed(t *testing.T) {
+	pcap := openMmapSequence(t, 1)
+	var logged []string
+	logf = func(format string, v ...any) { logged = append(logged, fmt.Sprintf(format, v...)) }
+	defer func() { logf = log.Printf }()
+	r := pcap.rd.(*mmapRegion)
+	runtime.SetFinalizer(r, nil)
+	r.finalize()
+	assert.Equal(t, []string{"lpcap: memory mapped capture was not closed"}, logged)
+}
+
+func TestOpenMmapRW(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mmaprw")
+	packets := []Packet{{PacketType: PacketTypeUnicast, Len: 3, Data: []byte{0, 1, 2}}}
+	if err := WriteFile(path, LinkTypeEthernet2, MaxSnapLength, packets); err != nil {
+		t.Fatal(err)
+	}
+	pcap, err := OpenMmapRW(path, WithBorrowed())
+	if err != nil {
+		t.Skip("memory mapping is not available:", err)
+	}
+	defer pcap.Close()
+
+	var read []*Packet
+	for i := 1; i <= 3; i++ {
+		p := new(Packet)
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		read = append(read, p)
+		// the next packet lies past the end of the current mapping
+		_, err := pcap.WritePacket(Packet{Index: uint16(i), PacketType: PacketTypeUnicast, Len: 3, Data: []byte{byte(i), 1, 2}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a read straddling the end of the mapping remaps the grown file
+	r := pcap.rd.(*remapFile)
+	old := len(r.region.data)
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Greater(t, len(r.region.data), old)
+	read = append(read, p)
+	assert.False(t, pcap.Next())
+
+	// packets borrowed from replaced mappings stay valid
+	runtime.GC()
+	for i, p := range read {
+		assert.Equal(t, uint16(i), p.Index)
+		data, err := p.Payload()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{byte(i), 1, 2}, data)
+	}
+
+	// until the file is closed
+	assert.NoError(t, pcap.Close())
+	for _, p := range read {
+		assert.True(t, p.region.done.Load())
+	}
+}
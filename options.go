@@ -0,0 +1,262 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"os"
+	"time"
+)
+
+// Option configures optional behaviour of PCAP
+type Option func(*options)
+
+type options struct {
+	flags    uint16 // file header flags set on Create
+	snapLen  uint32
+	link     LinkType
+	copyData bool
+
+	maxPacketSize   uint32
+	adaptiveSnapLen bool        // raise the snap length instead of rejecting packets
+	sync            bool        // open the created file with O_SYNC
+	mode            os.FileMode // permissions of the created file
+	borrowed        bool        // slice payloads from the mapping of OpenMmap
+	observer        Observer
+	dissector       Dissector
+	validator       func(Packet) error // see SetPayloadValidator
+	maxFileSize     int64              // WritePacket fails with ErrCaptureFull beyond it
+	noPool          bool               // allocate buffers instead of using the packet pool
+	maxPackets      int                // reads fail with ErrTooManyPackets beyond it
+	readAhead       int                // size of the read-ahead buffer
+	progress        ProgressFunc
+	prealloc        int64 // size of preallocated file
+
+	autoTimestamp bool
+	tsStart       time.Time
+	tsStep        time.Duration
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		snapLen:  MaxSnapLength,
+		link:     LinkTypeEthernet2,
+		copyData: true,
+		mode:     0644,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithSnapLength sets the maximum length of packets in the created file,
+// by default MaxSnapLength
+func WithSnapLength(n uint32) Option {
+	return func(o *options) {
+		o.snapLen = n
+	}
+}
+
+// WithLinkType sets the link type of the created file,
+// by default LinkTypeEthernet2
+func WithLinkType(lt LinkType) Option {
+	return func(o *options) {
+		o.link = lt
+	}
+}
+
+// WithSummaryFooter makes Close append a footer with the count and the
+// first and last timestamps of packets, see Summary
+func WithSummaryFooter() Option {
+	return func(o *options) {
+		o.flags |= FlagSummaryFooter
+	}
+}
+
+// WithPacketCount makes Create store the count of packets in the file
+// header, which is rewritten on Close, see Count
+func WithPacketCount() Option {
+	return func(o *options) {
+		o.flags |= FlagPacketCount
+	}
+}
+
+// WithTimestampDeltas makes Create store packet timestamps as signed
+// 32-bit nanosecond deltas from the previous packet, the first one from
+// the capture start, instead of the low 32 bits of the elapsed time. Gaps
+// between packets are then limited to about 2.1 seconds in both directions
+// but absolute times never wrap around, see WritePacketTime. Times are
+// only known by summing deltas, so SeekOffset, SetOffset and Resync scan
+// packet headers from the beginning of the file, which makes every seek
+// linear in the size of the file.
+func WithTimestampDeltas() Option {
+	return func(o *options) {
+		o.flags |= FlagTimestampDelta | FlagCaptureStart
+	}
+}
+
+// WithPacketFlags makes Create store the 16-bit Packet.Flags
+// field in the header of every written packet
+func WithPacketFlags() Option {
+	return func(o *options) {
+		o.flags |= FlagPacketFlags
+	}
+}
+
+// ProgressFunc receives the position reached by a long operation
+// and the total size of the file, both in bytes
+type ProgressFunc func(bytesDone, bytesTotal int64)
+
+// WithProgress sets fn to be called periodically while scanning or
+// copying the whole file. Calls are throttled to about every percent of
+// the file size, the final call reports bytesDone equal to bytesTotal.
+func WithProgress(fn ProgressFunc) Option {
+	return func(o *options) {
+		o.progress = fn
+	}
+}
+
+// WithPreallocate makes Create grow the file to size bytes up front and
+// write packets in place with a reused buffer, which saves allocations and
+// file size updates at high packet rates. Packets exceeding the size still
+// extend the file. Close cuts the file down to the written packets.
+// The writer must support WriteAt and Truncate.
+func WithPreallocate(size int64) Option {
+	return func(o *options) {
+		o.prealloc = size
+	}
+}
+
+// WithAutoTimestamp makes WritePacket ignore Packet.Timestamp and assign
+// start + step*n instead, where n is the count of previously written packets
+func WithAutoTimestamp(start time.Time, step time.Duration) Option {
+	return func(o *options) {
+		o.autoTimestamp = true
+		o.tsStart = start
+		o.tsStep = step
+	}
+}
+
+// WithCopyData controls whether ReadPacket returns Data in a new
+// allocation, which is the default. Disabling it avoids the allocation by
+// reading into a shared pooled buffer, which is overwritten by following
+// reads and writes. Only disable it if packets are never retained.
+func WithCopyData(copyData bool) Option {
+	return func(o *options) {
+		o.copyData = copyData
+	}
+}
+
+// WithAdaptiveSnapLength makes WritePacket raise the snap length of the
+// file up to MaxSnapLength and rewrite the file header, instead of
+// rejecting packets exceeding it. The writer must support WriteAt.
+func WithAdaptiveSnapLength() Option {
+	return func(o *options) {
+		o.adaptiveSnapLen = true
+	}
+}
+
+// WithObserver sets o to be notified of every packet read or written
+// and of every failed read or write
+func WithObserver(obs Observer) Option {
+	return func(o *options) {
+		o.observer = obs
+	}
+}
+
+// WithMaxFileSize makes WritePacket reject packets with ErrCaptureFull
+// once the file, including the summary footer, would exceed n bytes.
+// The file remains a valid capture of the packets written before.
+func WithMaxFileSize(n int64) Option {
+	return func(o *options) {
+		o.maxFileSize = n
+	}
+}
+
+// WithMaxPackets limits the count of packets read from the file to n,
+// reading more fails with ErrTooManyPackets. It protects against crafted
+// files of a huge count of tiny packets. Header scans, such as BuildIndex,
+// are limited as well.
+func WithMaxPackets(n int) Option {
+	return func(o *options) {
+		o.maxPackets = n
+	}
+}
+
+// WithReadAhead makes ReadPacket read the file in chunks of size bytes
+// and serve packet headers and data from the last chunk, which saves
+// system calls on sequential reads of small packets. Packets larger than
+// the chunk are read directly.
+func WithReadAhead(size int) Option {
+	return func(o *options) {
+		o.readAhead = size
+	}
+}
+
+// WithoutPool makes ReadPacket and WritePacket allocate new buffers
+// instead of reusing buffers of the internal packet pool, so Data read with
+// WithCopyData(false) is never overwritten by following reads and writes.
+func WithoutPool() Option {
+	return func(o *options) {
+		o.noPool = true
+	}
+}
+
+// WithDissector sets d to parse packet data read by ReadPacketDissect
+func WithDissector(d Dissector) Option {
+	return func(o *options) {
+		o.dissector = d
+	}
+}
+
+// WithMaxPacketSize makes ReadPacket reject packets longer than n bytes,
+// regardless of the snap length declared by the file, which limits memory
+// allocated for packets of untrusted files
+func WithMaxPacketSize(n uint32) Option {
+	return func(o *options) {
+		o.maxPacketSize = n
+	}
+}
+
+// WithSync makes Create and CreateAtomic open the file with O_SYNC, so
+// every WritePacket returns only after the packet reached the storage.
+// This survives crashes of the system, but limits the write rate to the
+// latency of the storage, which is often orders of magnitude lower than
+// writing to the page cache.
+func WithSync() Option {
+	return func(o *options) {
+		o.sync = true
+	}
+}
+
+// WithFileMode sets permissions of the file created by Create, by default
+// 0644, before the umask is applied. Captures may contain private traffic,
+// so 0600 is recommended for them. On Windows only the write permission
+// bit of the owner is used, a file without it is created read-only.
+func WithFileMode(mode os.FileMode) Option {
+	return func(o *options) {
+		o.mode = mode
+	}
+}
+
+// openFlags returns flags of os.OpenFile used to create files
+func (o *options) openFlags() int {
+	flags := os.O_RDWR | os.O_CREATE
+	if o.sync {
+		flags |= os.O_SYNC
+	}
+	return flags
+}
+
+// WithBorrowed makes ReadPacket of a PCAP opened by OpenMmap return Data
+// pointing into the memory mapping instead of a copy. Borrowed packets
+// must not be used after Close, which Packet.Payload checks. The mapping
+// stays valid as long as a packet read from it is reachable, so Data must
+// not be retained without its Packet. Data is read-only, writing to it
+// crashes the program.
+func WithBorrowed() Option {
+	return func(o *options) {
+		o.borrowed = true
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// ParallelForEach reads all packets of the file in n goroutines and calls
+// fn for every packet. The packets are split into n contiguous ranges by
+// BuildIndex, each range is read by its own Clone. fn is called
+// concurrently and must be safe for concurrent use, the packet and its
+// data are valid only until fn returns. The first error returned by fn or
+// by reading stops all goroutines and is returned.
+func (pcap *PCAP) ParallelForEach(n int, fn func(*Packet) error) error {
+	if n < 1 {
+		return errors.New("number of goroutines must be positive")
+	}
+	index, err := pcap.BuildIndex()
+	if err != nil {
+		return err
+	}
+	if n > len(index) {
+		n = len(index)
+	}
+
+	var (
+		wg       sync.WaitGroup
+		stopped  int32
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() { firstErr = err })
+		atomic.StoreInt32(&stopped, 1)
+	}
+	for i := 0; i < n; i++ {
+		start, end := len(index)*i/n, len(index)*(i+1)/n
+		c := pcap.Clone()
+		c.seekPacket(index[start])
+
+		wg.Add(1)
+		go func(c *PCAP, count int) {
+			defer wg.Done()
+			p := &Packet{Data: []byte{}}
+			for j := 0; j < count && atomic.LoadInt32(&stopped) == 0; j++ {
+				if _, err := c.readPacket(p, p.Data); err != nil {
+					fail(err)
+					return
+				}
+				if err := fn(p); err != nil {
+					fail(err)
+					return
+				}
+			}
+		}(c, end-start)
+	}
+	wg.Wait()
+	return firstErr
+}
+
+// ConcurrentWriter writes packets submitted from many goroutines to one
+// PCAP. Packets are queued to a single writer goroutine, so producers do
+// not contend for the PCAP, and they are written in the order they were
+// queued.
+type ConcurrentWriter struct {
+	pcap   *PCAP
+	queue  chan Packet
+	done   chan struct{}
+	mx     sync.RWMutex // guards closing of queue against Submit
+	closed bool
+	err    atomic.Value // first write error
+}
+
+// NewConcurrentWriter starts the writer goroutine writing to pcap, the
+// queue holds up to size packets before Submit blocks. The PCAP must not
+// be written directly until the ConcurrentWriter is closed.
+func NewConcurrentWriter(pcap *PCAP, size int) *ConcurrentWriter {
+	w := &ConcurrentWriter{
+		pcap:  pcap,
+		queue: make(chan Packet, size),
+		done:  make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *ConcurrentWriter) run() {
+	defer close(w.done)
+	for p := range w.queue {
+		if w.err.Load() != nil {
+			continue
+		}
+		if _, err := w.pcap.WritePacket(p); err != nil {
+			w.err.Store(err)
+		}
+	}
+}
+
+// Submit queues the packet to be written, blocking while the queue is
+// full. It is safe for concurrent use. Data must not be modified after
+// the call. After a write has failed, packets are discarded and Submit
+// returns the error of that write.
+func (w *ConcurrentWriter) Submit(p Packet) error {
+	w.mx.RLock()
+	defer w.mx.RUnlock()
+	if w.closed {
+		return errors.New("cannot submit packet, writer is closed")
+	}
+	if err, ok := w.err.Load().(error); ok {
+		return err
+	}
+	w.queue <- p
+	return nil
+}
+
+// Close waits until all queued packets are written and stops the writer
+// goroutine, returning the first write error. The PCAP is not closed.
+func (w *ConcurrentWriter) Close() error {
+	w.mx.Lock()
+	if w.closed {
+		w.mx.Unlock()
+		return errors.New("writer is already closed")
+	}
+	w.closed = true
+	close(w.queue)
+	w.mx.Unlock()
+
+	<-w.done
+	err, _ := w.err.Load().(error)
+	return err
+}
@@ -0,0 +1,155 @@
+package lpcap
+
+import (
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func createSequence(tb testing.TB, n int) *PCAP {
+	pcap, err := Create(filepath.Join(tb.TempDir(), "sequence"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	data := make([]byte, 4)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint32(data, uint32(i))
+		_, err := pcap.WritePacket(Packet{
+			Index:      uint16(i),
+			PacketType: PacketTypeUnicast,
+			Timestamp:  uint32(i),
+			Len:        uint32(len(data)),
+			Data:       data,
+		})
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return pcap
+}
+
+func TestParallelForEach(t *testing.T) {
+	const count = 100000
+	pcap := createSequence(t, count)
+	defer pcap.Close()
+
+	visited := make([]int32, count)
+	err := pcap.ParallelForEach(8, func(p *Packet) error {
+		atomic.AddInt32(&visited[binary.LittleEndian.Uint32(p.Data)], 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range visited {
+		if v != 1 {
+			t.Fatalf("packet %d visited %d times", i, v)
+		}
+	}
+
+	errStop := errors.New("stop")
+	err = pcap.ParallelForEach(4, func(p *Packet) error {
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+}
+
+func BenchmarkParallelForEach(b *testing.B) {
+	pcap := createSequence(b, 10000)
+	defer pcap.Close()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		err := pcap.ParallelForEach(4, func(p *Packet) error {
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestConcurrentWriter(t *testing.T) {
+	const producers, count = 8, 1000
+	path := filepath.Join(t.TempDir(), "concurrent")
+	pcap, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := NewConcurrentWriter(pcap, 64)
+	var wg sync.WaitGroup
+	for i := 0; i < producers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < count; j++ {
+				data := make([]byte, 4)
+				binary.LittleEndian.PutUint32(data, uint32(i*count+j))
+				err := w.Submit(Packet{PacketType: PacketTypeUnicast, Len: 4, Data: data})
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.NoError(t, w.Close())
+	assert.Error(t, w.Close())
+	assert.Error(t, w.Submit(Packet{PacketType: PacketTypeUnicast}))
+	assert.NoError(t, pcap.Close())
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	seen := make([]bool, producers*count)
+	for p, err := range pcap.Packets() {
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[binary.LittleEndian.Uint32(p.Data)] = true
+	}
+	assert.Equal(t, producers*count, pcap.Len())
+	assert.NotContains(t, seen, false)
+}
+
+func TestConcurrentWriterError(t *testing.T) {
+	w := NewConcurrentWriter(NewMemory(WithSnapLength(16)), 1)
+	assert.NoError(t, w.Submit(Packet{PacketType: PacketTypeUnicast, Len: 32, Data: make([]byte, 32)}))
+	err := w.Close()
+	assert.Error(t, err)
+}
+
+func BenchmarkConcurrentWriter(b *testing.B) {
+	pcap, err := Create(filepath.Join(b.TempDir(), "concurrent"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pcap.Close()
+	data := make([]byte, 128)
+
+	w := NewConcurrentWriter(pcap, 1024)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			err := w.Submit(Packet{PacketType: PacketTypeUnicast, Len: uint32(len(data)), Data: data})
+			if err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+	if err := w.Close(); err != nil {
+		b.Fatal(err)
+	}
+	b.StopTimer()
+	if n := pcap.written; n != int64(b.N) {
+		b.Fatalf("written %d packets, submitted %d", n, b.N)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Capacities of pooled buffers. Every buffer is taken from the pool of the
+// smallest class fitting the requested size, so small packets do not hold
+// buffers of MaxSnapLength.
+var poolClasses = [...]int{64, 256, 1024, 4096, MaxSnapLength}
+
+// Pools hold pointers to slices, see sync.Pool
+var packetPools [len(poolClasses)]sync.Pool
+
+var poolStats struct {
+	gets, allocs, allocBytes, puts, drops uint64
+}
+
+func init() {
+	for i := range packetPools {
+		size := poolClasses[i]
+		packetPools[i].New = func() any {
+			atomic.AddUint64(&poolStats.allocs, 1)
+			atomic.AddUint64(&poolStats.allocBytes, uint64(size))
+			b := make([]byte, 0, size)
+			return &b
+		}
+	}
+}
+
+// PoolUsage is a snapshot of counters of the internal packet pool,
+// shared by all PCAPs
+type PoolUsage struct {
+	// Buffers taken from the pool
+	Gets uint64
+	// Buffers allocated because the pool was empty
+	Allocs uint64
+	// Bytes of allocated buffers
+	AllocBytes uint64
+	// Buffers returned to the pool
+	Puts uint64
+	// Buffers not returned to the pool because they have no size class
+	Drops uint64
+}
+
+// PoolStats returns the counters of the internal packet pool
+func PoolStats() PoolUsage {
+	return PoolUsage{
+		Gets:       atomic.LoadUint64(&poolStats.gets),
+		Allocs:     atomic.LoadUint64(&poolStats.allocs),
+		AllocBytes: atomic.LoadUint64(&poolStats.allocBytes),
+		Puts:       atomic.LoadUint64(&poolStats.puts),
+		Drops:      atomic.LoadUint64(&poolStats.drops),
+	}
+}
+
+// poolClass returns the index of the smallest size class fitting size,
+// or -1 if size exceeds all classes
+func poolClass(size int) int {
+	for i, c := range poolClasses {
+		if size <= c {
+			return i
+		}
+	}
+	return -1
+}
+
+// getBuffer returns a buffer of the given length from the packet pool
+func getBuffer(size int) []byte {
+	i := poolClass(size)
+	if i < 0 {
+		return make([]byte, size)
+	}
+	atomic.AddUint64(&poolStats.gets, 1)
+	return (*packetPools[i].Get().(*[]byte))[:size]
+}
+
+// putBuffer returns a buffer got by getBuffer to the pool of its size
+// class, buffers of other capacities are left to the garbage collector
+func putBuffer(b []byte) {
+	i := poolClass(cap(b))
+	if i < 0 || poolClasses[i] != cap(b) {
+		atomic.AddUint64(&poolStats.drops, 1)
+		return
+	}
+	atomic.AddUint64(&poolStats.puts, 1)
+	b = b[:0]
+	packetPools[i].Put(&b)
+}
+
+// getBuffer returns a buffer of the given length from the packet pool,
+// or a new one if the pool is disabled by WithoutPool
+func (pcap *PCAP) getBuffer(size int) []byte {
+	if pcap.opts.noPool {
+		return make([]byte, size)
+	}
+	return getBuffer(size)
+}
+
+// putBuffer returns a buffer got by getBuffer to the packet pool
+func (pcap *PCAP) putBuffer(b []byte) {
+	if !pcap.opts.noPool {
+		putBuffer(b)
+	}
+}
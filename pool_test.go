@@ -0,0 +1,86 @@
+package lpcap
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolSizeClasses(t *testing.T) {
+	for _, size := range []int{0, 11, 64, 65, 1514, MaxSnapLength} {
+		b := getBuffer(size)
+		assert.Len(t, b, size)
+		assert.Equal(t, poolClasses[poolClass(size)], cap(b))
+		putBuffer(b)
+	}
+	assert.Len(t, getBuffer(MaxSnapLength+1), MaxSnapLength+1)
+
+	before := PoolStats()
+	putBuffer(make([]byte, 100))
+	assert.Equal(t, before.Drops+1, PoolStats().Drops)
+}
+
+func TestPoolSmallPackets(t *testing.T) {
+	before := PoolStats()
+	pcap := NewMemory(WithCopyData(false))
+	for i := 0; i < 1000; i++ {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 40, Data: make([]byte, 40)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	rd, err := OpenMemory(pcap.Bytes(), WithCopyData(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := new(Packet)
+	for rd.Next() {
+		if _, err := rd.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	after := PoolStats()
+	assert.Greater(t, after.Gets, before.Gets)
+	// only buffers of the smallest class were allocated
+	allocs := after.Allocs - before.Allocs
+	assert.LessOrEqual(t, after.AllocBytes-before.AllocBytes, allocs*uint64(poolClasses[0]))
+}
+
+// BenchmarkPoolSmallPackets reports bytes allocated by the packet pool per
+// packet read, which stays near the smallest size class for small payloads
+func BenchmarkPoolSmallPackets(b *testing.B) {
+	for _, size := range []int{40, 64, 1514} {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			w := NewMemory()
+			for i := 0; i < 1000; i++ {
+				_, err := w.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: uint32(size), Data: make([]byte, size)})
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+			data := w.Bytes()
+
+			b.ReportAllocs()
+			before := PoolStats()
+			b.ResetTimer()
+			p := new(Packet)
+			var rd *PCAP
+			for i := 0; i < b.N; i++ {
+				if rd == nil || !rd.Next() {
+					var err error
+					if rd, err = OpenMemory(data, WithCopyData(false)); err != nil {
+						b.Fatal(err)
+					}
+				}
+				if _, err := rd.ReadPacket(p); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			after := PoolStats()
+			b.ReportMetric(float64(after.AllocBytes-before.AllocBytes)/float64(b.N), "pool-B/packet")
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// preallocate grows the file to the preallocated size
+func (pcap *PCAP) preallocate() error {
+	_, isWriterAt := pcap.rd.(io.WriterAt)
+	t, isTruncater := pcap.rd.(truncater)
+	if !isWriterAt || !isTruncater {
+		return errors.New("cannot preallocate file, writer does not support WriteAt and Truncate")
+	}
+	if pcap.opts.prealloc <= atomic.LoadInt64(&pcap.fsize) {
+		return nil
+	}
+	return t.Truncate(pcap.opts.prealloc)
+}
+
+// writePacketAt writes the packet at the end of written data of
+// a preallocated file, reusing the same buffer for every packet
+func (pcap *PCAP) writePacketAt(p *Packet, size int) (int, error) {
+	if cap(pcap.wbuf) < size {
+		pcap.wbuf = make([]byte, size)
+	}
+	b := pcap.wbuf[:size]
+	offset := marshalPacketHeader(b, p, pcap.h)
+	copy(b[offset:], p.Data)
+	n, err := pcap.rd.(io.WriterAt).WriteAt(b, atomic.LoadInt64(&pcap.fsize))
+	if err != nil {
+		pcap.lasterr = ErrWrite
+		return 0, err
+	}
+	pcap.account(n, p)
+	return n, nil
+}
+
+// Truncate cuts the file down to the written data, releasing the space
+// preallocated by WithPreallocate, which Close does as well. Following
+// writes grow the file again. It does nothing if the writer does not
+// support Truncate, such as a stream.
+func (pcap *PCAP) Truncate() error {
+	if !pcap.writable {
+		return fmt.Errorf("cannot call Truncate, file is opened read-only: %w", ErrUnsupportedOperation)
+	}
+	return pcap.truncate()
+}
+
+// truncate cuts the file down to the written data
+func (pcap *PCAP) truncate() error {
+	t, ok := pcap.rd.(truncater)
+	if !ok {
+		return nil
+	}
+	if err := t.Truncate(atomic.LoadInt64(&pcap.fsize)); err != nil {
+		pcap.lasterr = ErrWrite
+		return err
+	}
+	pcap.resetReadAhead()
+	return nil
+}
+
+// FillLevel returns the count of written bytes and the preallocated size
+// of the file. Without preallocation both values are equal.
+func (pcap *PCAP) FillLevel() (written, allocated int64) {
+	written = atomic.LoadInt64(&pcap.fsize)
+	allocated = pcap.opts.prealloc
+	if allocated < written {
+		allocated = written
+	}
+	return written, allocated
+}
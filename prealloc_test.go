@@ -0,0 +1,127 @@
+package lpcap
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreallocate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prealloc")
+	pcap, err := Create(path, WithPreallocate(1<<16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(1<<16), s.Size())
+
+	for i := 0; i < 3; i++ {
+		_, err := pcap.WritePacket(Packet{
+			Index:      uint16(i),
+			PacketType: PacketTypeUnicast,
+			Len:        8,
+			Data:       make([]byte, 8),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	written, allocated := pcap.FillLevel()
+	assert.Equal(t, int64(createdHeaderSize+3*(createdPacketSize+8)), written)
+	assert.Equal(t, int64(1<<16), allocated)
+	assert.NoError(t, pcap.Close())
+
+	s, err = os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, written, s.Size())
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	index, err := pcap.BuildIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, index, 3)
+}
+
+func TestTruncate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "truncate")
+	pcap, err := Create(path, WithPreallocate(1<<20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func() {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 4, Data: make([]byte, 4)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	size := func() int64 {
+		s, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.Size()
+	}
+
+	write()
+	assert.NoError(t, pcap.Truncate())
+	assert.Equal(t, int64(createdHeaderSize+createdPacketSize+4), size())
+	write()
+	write()
+	assert.NoError(t, pcap.Close())
+	assert.Equal(t, int64(createdHeaderSize+3*(createdPacketSize+4)), size())
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	assert.ErrorIs(t, pcap.Truncate(), ErrUnsupportedOperation)
+	packets, err := pcap.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 3)
+
+	// without preallocation the file already ends at the written data
+	assert.NoError(t, NewMemory().Truncate())
+}
+
+func BenchmarkWritePacketPreallocated(b *testing.B) {
+	pcap, err := Create(filepath.Join(b.TempDir(), "prealloc"), WithPreallocate(int64(b.N)*(createdPacketSize+128)+createdHeaderSize))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pcap.Close()
+
+	data := make([]byte, 128)
+	if _, err := rand.Read(data); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		n, err := pcap.WritePacket(Packet{
+			Index:      0x4,
+			PacketType: PacketTypeBroadcast,
+			Timestamp:  uint32(time.Now().UnixNano()),
+			Len:        uint32(len(data)),
+			Data:       data,
+		})
+		if err != nil {
+			b.Fatal(err, n)
+		}
+	}
+}
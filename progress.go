@@ -0,0 +1,34 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+// Number of progress reports over the whole file
+const progressSteps = 100
+
+// progress throttles calls of ProgressFunc during a single operation
+type progress struct {
+	fn    ProgressFunc
+	total int64
+	step  int64
+	next  int64
+	last  int64 // last reported position
+}
+
+func (pcap *PCAP) newProgress(total int64) *progress {
+	step := total / progressSteps
+	if step < 1 {
+		step = 1
+	}
+	return &progress{fn: pcap.opts.progress, total: total, step: step, last: -1}
+}
+
+// update reports done bytes if enough progress was made since the last call
+func (p *progress) update(done int64) {
+	if p.fn == nil || done == p.last || (done < p.next && done < p.total) {
+		return
+	}
+	p.fn(done, p.total)
+	p.next = done + p.step
+	p.last = done
+}
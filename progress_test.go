@@ -0,0 +1,49 @@
+package lpcap
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "progress")
+	pcap, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		_, err := pcap.WritePacket(Packet{
+			PacketType: PacketTypeUnicast,
+			Len:        16,
+			Data:       make([]byte, 16),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	pcap.Close()
+
+	var done []int64
+	var total int64
+	pcap, err = Open(path, WithProgress(func(bytesDone, bytesTotal int64) {
+		done = append(done, bytesDone)
+		total = bytesTotal
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+
+	if _, err := pcap.BuildIndex(); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(createdHeaderSize+1000*(createdPacketSize+16)), total)
+	assert.Greater(t, len(done), 10)
+	assert.LessOrEqual(t, len(done), progressSteps+1)
+	for i := 1; i < len(done); i++ {
+		assert.Greater(t, done[i], done[i-1])
+	}
+	assert.Equal(t, total, done[len(done)-1])
+}
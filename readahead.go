@@ -0,0 +1,47 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// resetReadAhead drops the read-ahead buffer, it must be called whenever
+// the file is truncated or rewritten under the buffered bytes
+func (pcap *PCAP) resetReadAhead() {
+	pcap.ra = pcap.ra[:0]
+}
+
+// readAt reads len(b) bytes at offset off of the file, serving them from
+// the read-ahead buffer if enabled. Only bytes before the end of the file
+// are buffered, so bytes appended by following writes are never stale.
+func (pcap *PCAP) readAt(b []byte, off int64) (int, error) {
+	size := pcap.opts.readAhead
+	if size <= 0 || len(b) >= size {
+		return pcap.rd.ReadAt(b, off)
+	}
+	if off >= pcap.raOff && off+int64(len(b)) <= pcap.raOff+int64(len(pcap.ra)) {
+		return copy(b, pcap.ra[off-pcap.raOff:]), nil
+	}
+
+	n := int64(size)
+	if end := atomic.LoadInt64(&pcap.fsize); end-off < n {
+		n = end - off
+	}
+	if n < int64(len(b)) {
+		// the read crosses the end of the file, report it as is
+		return pcap.rd.ReadAt(b, off)
+	}
+	if cap(pcap.ra) < size {
+		pcap.ra = make([]byte, size)
+	}
+	pcap.ra = pcap.ra[:n]
+	if m, err := pcap.rd.ReadAt(pcap.ra, off); err != nil && (err != io.EOF || m != len(pcap.ra)) {
+		pcap.resetReadAhead()
+		return pcap.rd.ReadAt(b, off)
+	}
+	pcap.raOff = off
+	return copy(b, pcap.ra), nil
+}
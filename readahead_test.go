@@ -0,0 +1,122 @@
+package lpcap
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingFile counts ReadAt calls of the wrapped file
+type countingFile struct {
+	ReaderWriterCloser
+	reads int
+}
+
+func (f *countingFile) ReadAt(p []byte, off int64) (int, error) {
+	f.reads++
+	return f.ReaderWriterCloser.ReadAt(p, off)
+}
+
+func TestReadAhead(t *testing.T) {
+	w := NewMemory()
+	sizes := []int{1, 100, 0, 300, 7}
+	for i, n := range sizes {
+		data := make([]byte, n)
+		for j := range data {
+			data[j] = byte(i)
+		}
+		_, err := w.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: uint32(n), Data: data})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw := w.Bytes()
+
+	f := &countingFile{ReaderWriterCloser: NewMemBuffer(raw)}
+	pcap, err := NewReader(f, int64(len(raw)), WithReadAhead(256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.reads = 0
+	packets, err := pcap.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range packets {
+		assert.Len(t, p.Data, sizes[i])
+		for _, b := range p.Data {
+			assert.Equal(t, byte(i), b)
+		}
+	}
+	// the 300 bytes packet is read directly
+	assert.Less(t, f.reads, 2*len(sizes))
+
+	// seek back to the second packet
+	assert.NoError(t, pcap.SetOffset(pcap.DataOffset()+createdPacketSize+1))
+	p := new(Packet)
+	_, err = pcap.ReadPacket(p)
+	assert.NoError(t, err)
+	assert.Equal(t, packets[1].Data, p.Data)
+}
+
+func BenchmarkReadAhead(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "readahead")
+	packets := make([]Packet, 10000)
+	for i := range packets {
+		packets[i] = Packet{PacketType: PacketTypeUnicast, Len: 64, Data: make([]byte, 64)}
+	}
+	if err := WriteFile(path, LinkTypeEthernet2, MaxSnapLength, packets); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, bench := range []struct {
+		name string
+		size int
+	}{
+		{"Disabled", 0},
+		{"64KiB", 64 << 10},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				pcap, err := Open(path, WithReadAhead(bench.size), WithCopyData(false))
+				if err != nil {
+					b.Fatal(err)
+				}
+				p := new(Packet)
+				for pcap.Next() {
+					if _, err := pcap.ReadPacket(p); err != nil {
+						b.Fatal(err)
+					}
+				}
+				pcap.Close()
+			}
+		})
+	}
+}
+
+func TestReadAheadDrainTo(t *testing.T) {
+	pcap := NewMemory(WithReadAhead(256))
+	for i := 0; i < 3; i++ {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 2, Data: []byte{byte(i), byte(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := pcap.DrainTo(NewMemory()); err != nil {
+		t.Fatal(err)
+	}
+
+	// the packet is written over the buffered bytes of the drained ones
+	_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 2, Data: []byte{9, 9}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []byte{9, 9}, p.Data)
+	assert.False(t, pcap.Next())
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"io"
+	"sync/atomic"
+)
+
+// Maximum number of bytes ScanForHeader looks through before giving up
+const MaxScanLength = 1 << 20
+
+// ScanForHeader reads forward from offset looking for the first position
+// that parses as a valid packet header whose payload fits into the file,
+// and returns that position. Scanning stops after MaxScanLength bytes.
+// It does not move the read offset.
+func (pcap *PCAP) ScanForHeader(offset int64) (int64, error) {
+	fsize := atomic.LoadInt64(&pcap.fsize)
+	hsize := int64(pcap.h.packetHeaderSize())
+	return pcap.scanHeaders(offset, func(at int64, h *packetHeader) bool {
+		return at+hsize+int64(h.len) <= fsize
+	})
+}
+
+// Resync moves the read offset forward to the next packet boundary after
+// a damaged region, and returns the number of bytes skipped. A position is
+// accepted if it parses as a packet header and the packet implied by it is
+// followed by another valid packet header, a file header, or the end of
+// the file. Scanning stops after MaxScanLength bytes.
+func (pcap *PCAP) Resync() (int64, error) {
+	offset := atomic.LoadInt64(&pcap.offset)
+	fsize := atomic.LoadInt64(&pcap.fsize)
+	hsize := int64(pcap.h.packetHeaderSize())
+	at, err := pcap.scanHeaders(offset, func(at int64, h *packetHeader) bool {
+		end := at + hsize + int64(h.len)
+		return end <= fsize && pcap.atBoundary(end, fsize) == nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if pcap.h.flags&FlagTimestampDelta != 0 {
+		// deltas of damaged packets are lost, if headers before at cannot
+		// be scanned, times continue from the last packet read
+		if t, err := pcap.timeAt(at); err == nil {
+			pcap.prevTime = t
+		}
+	}
+	atomic.StoreInt64(&pcap.offset, at)
+	pcap.resetReadAhead()
+	return at - offset, nil
+}
+
+// SeekByte moves the read offset to off, counted from the beginning of the
+// file, after checking that a packet starts there: off must parse as a
+// packet header whose packet is followed by another packet header, a file
+// header, a summary footer, or the end of the file. Offsets of a file
+// header of a concatenated capture, of a footer and of the end of the file
+// are accepted as well. Misaligned offsets are reported by ParseError and
+// the read offset is not moved.
+func (pcap *PCAP) SeekByte(off int64) error {
+	fsize := atomic.LoadInt64(&pcap.fsize)
+	if off < int64(pcap.h.size) || off > fsize {
+		return errors.New("seek offset is out of packets range")
+	}
+	if off < fsize {
+		h, err := pcap.readPacketHeader(off)
+		switch {
+		case err == errNotPacket:
+		case err != nil:
+			return err
+		default:
+			// the packet must be followed by another boundary
+			end := off + int64(pcap.h.packetHeaderSize()) + int64(h.len)
+			if end > fsize {
+				return &ParseError{Offset: off + 6, Err: io.ErrUnexpectedEOF}
+			}
+			if err := pcap.atBoundary(end, fsize); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := pcap.SeekOffset(off, io.SeekStart)
+	return err
+}
+
+// atBoundary checks that off is the end of the file, or the offset of a
+// file header, of a summary footer or of a valid packet header
+func (pcap *PCAP) atBoundary(off, fsize int64) error {
+	if off == fsize {
+		return nil
+	}
+	_, err := pcap.readPacketHeader(off)
+	if err == errNotPacket {
+		return nil
+	}
+	return err
+}
+
+// errNotPacket is returned by readPacketHeader for offsets of a file header
+// or of a summary footer
+var errNotPacket = errors.New("not a packet header")
+
+// readPacketHeader parses the packet header at off, using the active file
+// header. errNotPacket is returned for a file header or a summary footer.
+func (pcap *PCAP) readPacketHeader(off int64) (*packetHeader, error) {
+	b := make([]byte, pcap.h.packetHeaderSize())
+	if n, err := pcap.rd.ReadAt(b, off); err != nil && (err != io.EOF || n < len(b)) {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, &ParseError{Offset: off, Err: err}
+	}
+	if hasMagic(b) || hasFooterMagic(b) {
+		return nil, errNotPacket
+	}
+	h, erroffset, err := unmarshalPacketHeader(b, pcap.h)
+	if err != nil {
+		return nil, &ParseError{Offset: off + erroffset, Err: err}
+	}
+	return h, nil
+}
+
+// scanHeaders reads forward from offset and returns the first position
+// that parses as a packet header accepted by fn. Scanning stops after
+// MaxScanLength bytes. It does not move the read offset.
+func (pcap *PCAP) scanHeaders(offset int64, fn func(at int64, h *packetHeader) bool) (int64, error) {
+	fsize := atomic.LoadInt64(&pcap.fsize)
+	if offset < int64(pcap.h.size) {
+		offset = int64(pcap.h.size)
+	}
+	if offset >= fsize {
+		return 0, io.EOF
+	}
+
+	end := offset + MaxScanLength
+	if end > fsize {
+		end = fsize
+	}
+
+	// read the window in chunks overlapping by a header length,
+	// so a header crossing the chunk border is not missed
+	hsize := pcap.h.packetHeaderSize()
+	b := make([]byte, MaxSnapLength+hsize)
+	for pos := offset; pos < end; pos += MaxSnapLength {
+		n, err := pcap.rd.ReadAt(b, pos)
+		if err != nil && err != io.EOF {
+			pcap.lasterr = ErrRead
+			return 0, err
+		}
+		for i := 0; i+hsize <= n && pos+int64(i) < end; i++ {
+			h, _, err := unmarshalPacketHeader(b[i:], pcap.h)
+			if err != nil {
+				continue
+			}
+			if at := pos + int64(i); fn(at, h) {
+				return at, nil
+			}
+		}
+	}
+	return 0, errors.New("no valid packet header found within scan limit")
+}
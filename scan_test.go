@@ -0,0 +1,145 @@
+package lpcap
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScanForHeader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "scan")
+	pcap, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pcap.WritePacket(Packet{
+		Index:      1,
+		PacketType: PacketTypeMulticast,
+		Timestamp:  0x01010101,
+		Len:        16,
+		Data:       make([]byte, 16),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcap.Close()
+
+	// put junk between the file header and the packet
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	junk := bytes.Repeat([]byte{0xff}, 37)
+	raw = append(raw[:createdHeaderSize:createdHeaderSize], append(junk, raw[createdHeaderSize:]...)...)
+	if err := os.WriteFile(path, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+
+	offset, err := pcap.ScanForHeader(createdHeaderSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(createdHeaderSize+len(junk)), offset)
+
+	_, err = pcap.ScanForHeader(offset + 1)
+	assert.Error(t, err)
+}
+
+func TestResync(t *testing.T) {
+	pcap := NewMemory()
+	for i := 0; i < 4; i++ {
+		_, err := pcap.WritePacket(Packet{
+			Index:      uint16(i),
+			PacketType: PacketTypeUnicast,
+			Timestamp:  uint32(i),
+			Len:        8,
+			Data:       make([]byte, 8),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// break the packet type of the second packet
+	raw := pcap.Bytes()
+	const size = createdPacketSize + 8
+	raw[createdHeaderSize+size+1] = 0xff
+
+	rd, err := OpenMemory(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := new(Packet)
+	if _, err := rd.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	_, err = rd.ReadPacket(p)
+	assert.Error(t, err)
+
+	skipped, err := rd.Resync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(8), skipped)
+	if _, err := rd.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint16(2), p.Index)
+}
+
+func TestSeekByte(t *testing.T) {
+	pcap := NewMemory()
+	// the payload of the first packet starts with a header of an empty
+	// packet, which is not followed by a valid one
+	fake := make([]byte, createdPacketSize)
+	marshalPacketHeader(fake, &Packet{PacketType: PacketTypeUnicast}, pcap.h)
+	payloads := [][]byte{
+		append(fake, 0xff, 0xff, 0xff),
+		bytes.Repeat([]byte{0xff}, 8),
+	}
+	for i, data := range payloads {
+		_, err := pcap.WritePacket(Packet{
+			Index:      uint16(i),
+			PacketType: PacketTypeUnicast,
+			Len:        uint32(len(data)),
+			Data:       data,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rd, err := OpenMemory(pcap.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := int64(createdHeaderSize + createdPacketSize + len(payloads[0]))
+	assert.NoError(t, rd.SeekByte(second))
+	p := new(Packet)
+	if _, err := rd.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint16(1), p.Index)
+	assert.NoError(t, rd.SeekByte(rd.Size()))
+	assert.False(t, rd.Next())
+
+	assert.NoError(t, rd.SeekByte(createdHeaderSize))
+	var perr *ParseError
+	assert.ErrorAs(t, rd.SeekByte(second+createdPacketSize+2), &perr)
+	assert.Error(t, rd.SeekByte(createdHeaderSize+createdPacketSize))
+	assert.Error(t, rd.SeekByte(rd.Size()+1))
+	// the read offset is not moved by misaligned offsets
+	if _, err := rd.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint16(0), p.Index)
+}
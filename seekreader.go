@@ -0,0 +1,58 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// seekReader implements ReaderAt over io.ReadSeeker. Seek and Read are not
+// safe for concurrent use, so every ReadAt is serialized with a mutex.
+type seekReader struct {
+	mx sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (r *seekReader) Read(p []byte) (int, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	return r.rs.Read(p)
+}
+
+func (r *seekReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if _, err := r.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(r.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (r *seekReader) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("WritePacket: %w", ErrUnsupportedOperation)
+}
+
+func (r *seekReader) Close() error {
+	if c, ok := r.rs.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// NewSeekReader returns a read-only PCAP reading packets from rs, for
+// sources which do not implement io.ReaderAt. Reads are serialized, since
+// every read seeks rs. Close closes rs if it implements io.Closer.
+func NewSeekReader(rs io.ReadSeeker, opts ...Option) (*PCAP, error) {
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	return newReader(&seekReader{rs: rs}, size, newOptions(opts))
+}
@@ -0,0 +1,47 @@
+package lpcap
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// readSeeker hides every method of the wrapped reader except Read and Seek
+type readSeeker struct {
+	io.ReadSeeker
+}
+
+func TestNewSeekReader(t *testing.T) {
+	src := createSequence(t, 5)
+	index, err := src.BuildIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := NewMemory()
+	if _, err := Transform(raw, src, func(p *Packet) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	src.Close()
+
+	pcap, err := NewSeekReader(readSeeker{bytes.NewReader(raw.Bytes())})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+
+	packets, err := pcap.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, packets, len(index)) {
+		for i, p := range packets {
+			assert.Equal(t, index[i].Index, p.Index)
+			assert.Equal(t, []byte{byte(i), 0, 0, 0}, p.Data)
+		}
+	}
+
+	_, err = pcap.WritePacket(Packet{PacketType: PacketTypeUnicast})
+	assert.Error(t, err)
+}
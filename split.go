@@ -0,0 +1,170 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// SplitBySize reads packets of src from its current offset and writes them
+// to destinations created by open with sequence numbers starting from 0.
+// A new destination is started before a packet would make the current one
+// larger than maxBytes, including its file header. Every destination is
+// closed once it is complete. Destinations must have the link type and
+// snap length of src. Returns the count of packets in every destination.
+func SplitBySize(src *PCAP, maxBytes int64, open func(seq int) (*PCAP, error)) ([]int, error) {
+	return split(src, open, func(dst *PCAP, count int, p *Packet) (bool, error) {
+		size := int64(dst.h.packetHeaderSize()) + int64(p.Len)
+		if atomic.LoadInt64(&dst.fsize)+size <= maxBytes {
+			return false, nil
+		}
+		if count == 0 || int64(dst.h.size)+size > maxBytes {
+			return false, errors.New("cannot split PCAP, packet does not fit into maximum size")
+		}
+		return true, nil
+	})
+}
+
+// SplitByCount reads packets of src from its current offset and writes
+// them to destinations created by open with sequence numbers starting
+// from 0, perFile packets to each, the last one may have fewer. Every
+// destination is closed once it is complete. Destinations must have the
+// link type and snap length of src.
+func SplitByCount(src *PCAP, perFile int, open func(seq int) (*PCAP, error)) error {
+	if perFile < 1 {
+		return errors.New("number of packets per file must be positive")
+	}
+	_, err := split(src, open, func(dst *PCAP, count int, p *Packet) (bool, error) {
+		return count == perFile, nil
+	})
+	return err
+}
+
+// SplitByIndex reads packets of pcap from the current offset and writes
+// them to separate files by interface index. The file of every index is
+// created on its first packet with the link type, snap length, header
+// flags, capture start and interface name of pcap. Its path is basePath
+// with the index inserted before the extension, "out.lpcap" becomes
+// "out.1.lpcap". Returns the paths of created files by index.
+func (pcap *PCAP) SplitByIndex(basePath string) (map[uint16]string, error) {
+	ext := filepath.Ext(basePath)
+	paths := make(map[uint16]string)
+	dsts := make(map[uint16]*PCAP)
+	closeAll := func(err error) error {
+		for _, dst := range dsts {
+			err = errors.Join(err, dst.Close())
+		}
+		return err
+	}
+
+	p := &Packet{Data: []byte{}}
+	for pcap.Next() {
+		if _, err := pcap.readPacket(p, p.Data); err != nil {
+			return paths, closeAll(err)
+		}
+		dst, ok := dsts[p.Index]
+		if !ok {
+			path := fmt.Sprintf("%s.%d%s", strings.TrimSuffix(basePath, ext), p.Index, ext)
+			var err error
+			if dst, err = pcap.createLike(path, p.Index); err != nil {
+				return paths, closeAll(err)
+			}
+			dsts[p.Index] = dst
+			paths[p.Index] = path
+		}
+		if _, err := dst.WritePacket(*p); err != nil {
+			return paths, closeAll(err)
+		}
+	}
+	return paths, closeAll(nil)
+}
+
+// createLike creates a file on path with the file header of pcap,
+// naming only the interface index
+func (pcap *PCAP) createLike(path string, index uint16) (*PCAP, error) {
+	opts := []Option{WithLinkType(pcap.h.link), WithSnapLength(pcap.h.snapLen)}
+	if pcap.h.flags&FlagPacketFlags != 0 {
+		opts = append(opts, WithPacketFlags())
+	}
+	if pcap.h.flags&FlagSummaryFooter != 0 {
+		opts = append(opts, WithSummaryFooter())
+	}
+	if pcap.h.flags&FlagPacketCount != 0 {
+		opts = append(opts, WithPacketCount())
+	}
+	dst, err := Create(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if start, ok := pcap.CaptureStart(); ok {
+		err = dst.SetCaptureStart(start)
+	}
+	if name, ok := pcap.InterfaceName(index); ok && err == nil {
+		err = dst.AddInterface(index, name)
+	}
+	if err != nil {
+		return nil, errors.Join(err, dst.Close())
+	}
+	return dst, nil
+}
+
+// split writes packets of src to destinations created by open, starting
+// a new one whenever roll reports so for the packet about to be written
+// to dst already holding count packets.
+func split(src *PCAP, open func(seq int) (*PCAP, error), roll func(dst *PCAP, count int, p *Packet) (bool, error)) ([]int, error) {
+	var (
+		counts []int
+		dst    *PCAP
+	)
+	p := &Packet{Data: []byte{}}
+	for src.Next() {
+		if _, err := src.readPacket(p, p.Data); err != nil {
+			return counts, closeSplit(dst, err)
+		}
+		if dst != nil {
+			next, err := roll(dst, counts[len(counts)-1], p)
+			if err != nil {
+				return counts, closeSplit(dst, err)
+			}
+			if next {
+				if err := dst.Close(); err != nil {
+					return counts, err
+				}
+				dst = nil
+			}
+		}
+		if dst == nil {
+			var err error
+			if dst, err = open(len(counts)); err != nil {
+				return counts, err
+			}
+			counts = append(counts, 0)
+			if dst.h.link != src.h.link || dst.h.snapLen != src.h.snapLen {
+				return counts, closeSplit(dst, errors.New("cannot split PCAP, destination link type or snap length differs from source"))
+			}
+			// the first packet of a destination may not fit as well
+			if _, err := roll(dst, 0, p); err != nil {
+				return counts, closeSplit(dst, err)
+			}
+		}
+		if _, err := dst.WritePacket(*p); err != nil {
+			return counts, closeSplit(dst, err)
+		}
+		counts[len(counts)-1]++
+	}
+	return counts, closeSplit(dst, nil)
+}
+
+// closeSplit closes the current destination of split, if any,
+// and combines the error of closing with err
+func closeSplit(dst *PCAP, err error) error {
+	if dst == nil {
+		return err
+	}
+	return errors.Join(err, dst.Close())
+}
@@ -0,0 +1,143 @@
+package lpcap
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// splitPaths returns open function of split creating files in a temporary
+// directory and the list of created paths
+func splitPaths(t *testing.T) (func(seq int) (*PCAP, error), *[]string) {
+	dir := t.TempDir()
+	var paths []string
+	return func(seq int) (*PCAP, error) {
+		path := filepath.Join(dir, fmt.Sprintf("part%d", seq))
+		paths = append(paths, path)
+		return Create(path)
+	}, &paths
+}
+
+// readSplit reads packets of all files and returns their indexes
+func readSplit(t *testing.T, paths []string) []uint16 {
+	var indexes []uint16
+	for _, path := range paths {
+		pcap, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		packets, err := pcap.ReadAll()
+		pcap.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range packets {
+			indexes = append(indexes, p.Index)
+		}
+	}
+	return indexes
+}
+
+func TestSplitBySize(t *testing.T) {
+	src := createSequence(t, 10)
+	defer src.Close()
+	open, paths := splitPaths(t)
+
+	const size = createdPacketSize + 4
+	counts, err := SplitBySize(src, createdHeaderSize+4*size, open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []int{4, 4, 2}, counts)
+	assert.Len(t, *paths, 3)
+	assert.Equal(t, []uint16{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, readSplit(t, *paths))
+
+	big := createSequence(t, 1)
+	defer big.Close()
+	_, err = SplitBySize(big, createdHeaderSize+size-1, open)
+	assert.Error(t, err)
+}
+
+func TestSplitByCount(t *testing.T) {
+	src := createSequence(t, 10)
+	defer src.Close()
+	open, paths := splitPaths(t)
+
+	if err := SplitByCount(src, 3, open); err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, *paths, 4)
+	assert.Equal(t, []uint16{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, readSplit(t, *paths))
+
+	last, err := Open((*paths)[3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer last.Close()
+	assert.Equal(t, src.LinkType(), last.LinkType())
+	assert.Equal(t, src.h.snapLen, last.h.snapLen)
+	packets, err := last.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 1)
+}
+
+func TestSplitLinkTypeMismatch(t *testing.T) {
+	src := createSequence(t, 2)
+	defer src.Close()
+	dir := t.TempDir()
+	err := SplitByCount(src, 1, func(seq int) (*PCAP, error) {
+		return Create(filepath.Join(dir, fmt.Sprintf("part%d", seq)), WithLinkType(LinkTypeEthernet80211))
+	})
+	assert.Error(t, err)
+}
+
+func TestSplitByIndex(t *testing.T) {
+	pcap := NewMemory(WithPacketFlags(), WithLinkType(LinkTypeEthernet80211))
+	assert.NoError(t, pcap.AddInterface(1, "eth1"))
+	for i := 0; i < 9; i++ {
+		_, err := pcap.WritePacket(Packet{
+			Index:      uint16(i % 3),
+			PacketType: PacketTypeUnicast,
+			Len:        1,
+			Data:       []byte{byte(i)},
+			Flags:      uint16(i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	base := filepath.Join(t.TempDir(), "out.lpcap")
+	paths, err := pcap.SplitByIndex(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, paths, 3)
+	assert.Equal(t, filepath.Join(filepath.Dir(base), "out.1.lpcap"), paths[1])
+
+	for index := uint16(0); index < 3; index++ {
+		dst, err := Open(paths[index])
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, LinkTypeEthernet80211, dst.LinkType())
+		name, ok := dst.InterfaceName(1)
+		assert.Equal(t, index == 1, ok)
+		if ok {
+			assert.Equal(t, "eth1", name)
+		}
+		packets, err := dst.ReadAll()
+		dst.Close()
+		assert.NoError(t, err)
+		if assert.Len(t, packets, 3) {
+			for j, p := range packets {
+				i := int(index) + 3*j
+				assert.Equal(t, index, p.Index)
+				assert.Equal(t, []byte{byte(i)}, p.Data)
+				assert.Equal(t, uint16(i), p.Flags)
+			}
+		}
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// CountByType counts packets of every type in the file. Only packet
+// headers are read and the read offset is not moved.
+func (pcap *PCAP) CountByType() (broadcast, unicast, multicast int, err error) {
+	err = pcap.scan(func(info PacketInfo) error {
+		switch info.PacketType {
+		case PacketTypeBroadcast:
+			broadcast++
+		case PacketTypeUnicast:
+			unicast++
+		case PacketTypeMulticast:
+			multicast++
+		}
+		return nil
+	})
+	return broadcast, unicast, multicast, err
+}
+
+// Maximum count of buckets returned by RateHistogram
+const MaxRateBuckets = 1 << 24
+
+// RateHistogram counts packets in consecutive buckets of the given
+// duration, from the timestamp of the first packet to the timestamp of the
+// last one. Timestamps are nanoseconds and are expected not to decrease, a
+// decreasing timestamp is taken as a wraparound of the 32-bit counter.
+// It fails if more than MaxRateBuckets buckets would be needed, such as
+// for a tiny bucket over a long capture. Only packet headers are read and
+// the read offset is not moved.
+func (pcap *PCAP) RateHistogram(bucket time.Duration) ([]int, error) {
+	if bucket <= 0 {
+		return nil, errors.New("bucket duration must be positive")
+	}
+	var (
+		counts  []int
+		first   = true
+		prev    uint32
+		elapsed time.Duration
+	)
+	err := pcap.scan(func(info PacketInfo) error {
+		if first {
+			first = false
+		} else {
+			// unsigned difference is correct across a wraparound
+			elapsed += time.Duration(info.elapsed - prev)
+		}
+		prev = info.elapsed
+		i := elapsed / bucket
+		if i >= MaxRateBuckets {
+			return fmt.Errorf("cannot count packets, more than %d buckets of %v", MaxRateBuckets, bucket)
+		}
+		if n := int(i) + 1; n > len(counts) {
+			// grown capacity is zeroed and counts never shrinks
+			counts = slices.Grow(counts, n-len(counts))[:n]
+		}
+		counts[i]++
+		return nil
+	})
+	return counts, err
+}
+
+// SizeHistogram counts packets by data length in buckets of bucketBytes,
+// the key of a bucket is the smallest length it counts, so a 70 bytes
+// packet falls into the bucket 64 of 32 bytes buckets. Only packet headers
+// are read and the read offset is not moved.
+func (pcap *PCAP) SizeHistogram(bucketBytes int) (map[int]int, error) {
+	if bucketBytes <= 0 {
+		return nil, errors.New("bucket size must be positive")
+	}
+	counts := make(map[int]int)
+	err := pcap.scan(func(info PacketInfo) error {
+		counts[int(info.Len)/bucketBytes*bucketBytes]++
+		return nil
+	})
+	return counts, err
+}
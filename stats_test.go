@@ -0,0 +1,106 @@
+package lpcap
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountByType(t *testing.T) {
+	pcap := NewMemory()
+	types := []uint8{
+		PacketTypeUnicast, PacketTypeMulticast, PacketTypeUnicast,
+		PacketTypeBroadcast, PacketTypeUnicast, PacketTypeMulticast,
+	}
+	for _, pt := range types {
+		_, err := pcap.WritePacket(Packet{PacketType: pt, Len: 1, Data: []byte{0}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	offset := pcap.offset
+
+	broadcast, unicast, multicast, err := pcap.CountByType()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, broadcast)
+	assert.Equal(t, 3, unicast)
+	assert.Equal(t, 2, multicast)
+	assert.Equal(t, offset, pcap.offset)
+}
+
+func TestRateHistogram(t *testing.T) {
+	pcap := NewMemory()
+	// 3 packets in the first second, none in the second, 2 in the third,
+	// the last one after a wraparound of the timestamp counter
+	base := uint32(math.MaxUint32 - 2500*uint32(time.Millisecond))
+	for _, ms := range []uint32{0, 100, 900, 2000, 2600} {
+		ts := base + ms*uint32(time.Millisecond)
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Timestamp: ts, Len: 1, Data: []byte{0}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	counts, err := pcap.RateHistogram(time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []int{3, 0, 2}, counts)
+
+	counts, err = pcap.RateHistogram(10 * time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{5}, counts)
+
+	_, err = pcap.RateHistogram(0)
+	assert.Error(t, err)
+
+	counts, err = NewMemory().RateHistogram(time.Second)
+	assert.NoError(t, err)
+	assert.Empty(t, counts)
+}
+
+func TestRateHistogramLimit(t *testing.T) {
+	pcap := NewMemory()
+	// every decreasing timestamp adds a wraparound of about 4.3 seconds
+	for _, ts := range []uint32{3e9, 1e9, 2e9, 1e9} {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Timestamp: ts, Len: 1, Data: []byte{1}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := pcap.RateHistogram(time.Nanosecond)
+	assert.ErrorContains(t, err, "buckets")
+
+	counts, err := pcap.RateHistogram(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 0, 1, 1, 0, 0, 1}, counts)
+}
+
+func TestSizeHistogram(t *testing.T) {
+	pcap := NewMemory()
+	for _, n := range []int{0, 31, 32, 70, 64, 1500, 95} {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: uint32(n), Data: make([]byte, n)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	offset := pcap.Tell()
+
+	counts, err := pcap.SizeHistogram(32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[int]int{0: 2, 32: 1, 64: 3, 1472: 1}, counts)
+	assert.Equal(t, offset, pcap.Tell())
+
+	_, err = pcap.SizeHistogram(0)
+	assert.Error(t, err)
+}
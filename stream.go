@@ -0,0 +1,60 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// streamWriter implements ReaderWriterCloser over io.Writer for
+// write-only sinks such as pipes, which cannot be read back, so reads
+// fail with ErrUnsupportedOperation
+type streamWriter struct {
+	w io.Writer
+}
+
+func (s streamWriter) Read(p []byte) (int, error) {
+	return 0, fmt.Errorf("ReadPacket: %w", ErrUnsupportedOperation)
+}
+
+func (s streamWriter) ReadAt(p []byte, off int64) (int, error) {
+	return 0, fmt.Errorf("ReadPacket: %w", ErrUnsupportedOperation)
+}
+
+func (s streamWriter) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+func (s streamWriter) Close() error {
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// NewStreamWriter writes the file header to w and returns a write-only
+// PCAP appending packets to it, for sinks without io.ReaderAt such as a
+// pipe or a network connection. Close closes w if it implements io.Closer.
+// Reading packets fails with ErrUnsupportedOperation.
+// Nothing written can be rewritten, so WithPacketCount and WithPreallocate
+// are not supported, and neither are methods rewriting the file header
+// after the first packet, such as SetCaptureStart.
+func NewStreamWriter(w io.Writer, opts ...Option) (*PCAP, error) {
+	return newStreamWriter(streamWriter{w}, newOptions(opts))
+}
+
+// NewStdoutWriter is like NewStreamWriter writing to os.Stdout, so a live
+// capture can be piped to another program. Close does not close os.Stdout.
+func NewStdoutWriter(opts ...Option) (*PCAP, error) {
+	return newStreamWriter(callerFile{os.Stdout}, newOptions(opts))
+}
+
+func newStreamWriter(rw ReaderWriterCloser, o options) (*PCAP, error) {
+	if o.flags&FlagPacketCount != 0 || o.prealloc > 0 {
+		return nil, fmt.Errorf("cannot rewrite a stream, packet count and preallocation are not supported: %w", ErrUnsupportedOperation)
+	}
+	return newWriter(rw, o)
+}
@@ -0,0 +1,103 @@
+package lpcap
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamWriter(t *testing.T) {
+	r, w := io.Pipe()
+	errc := make(chan error, 1)
+	go func() {
+		pcap, err := NewStreamWriter(w, WithSummaryFooter())
+		if err != nil {
+			w.CloseWithError(err)
+			errc <- err
+			return
+		}
+		for i := 0; i < 5; i++ {
+			_, err := pcap.WritePacket(Packet{Index: uint16(i), PacketType: PacketTypeUnicast, Len: 1, Data: []byte{byte(i)}})
+			if err != nil {
+				errc <- err
+				return
+			}
+		}
+		// closes the write end, ending the reader
+		errc <- pcap.Close()
+	}()
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, <-errc)
+	rd, err := OpenMemory(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := rd.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 5)
+	assert.Equal(t, uint16(4), packets[4].Index)
+	s, err := rd.Summary()
+	assert.NoError(t, err)
+	assert.Equal(t, 5, s.Count)
+
+	_, err = NewStreamWriter(io.Discard, WithPacketCount())
+	assert.ErrorIs(t, err, ErrUnsupportedOperation)
+	pcap, err := NewStreamWriter(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, pcap.Next())
+}
+
+func TestStreamUnsupportedOperation(t *testing.T) {
+	w, err := NewStreamWriter(io.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{1}})
+	assert.NoError(t, err)
+	_, err = w.ReadPacket(new(Packet))
+	assert.True(t, errors.Is(err, ErrUnsupportedOperation))
+	assert.ErrorContains(t, err, "ReadPacket")
+	_, err = w.rd.Read(make([]byte, 1))
+	assert.True(t, errors.Is(err, ErrUnsupportedOperation))
+}
+
+func TestStdoutWriter(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	pcap, err := NewStdoutWriter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 2, Data: []byte{1, 2}})
+	assert.NoError(t, err)
+	assert.NoError(t, pcap.Close())
+	// the PCAP does not close stdout
+	assert.NoError(t, w.Close())
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rd, err := OpenMemory(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := rd.ReadAll()
+	assert.NoError(t, err)
+	assert.Len(t, packets, 1)
+}
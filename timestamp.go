@@ -0,0 +1,157 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// SetCaptureStart stores the capture start time in the file header,
+// making packet timestamps relative to it. It must be called before the
+// first packet is written, afterwards the start can only be changed if it
+// was already set, because the header must not grow.
+func (pcap *PCAP) SetCaptureStart(t time.Time) error {
+	if !pcap.writable {
+		return fmt.Errorf("cannot call SetCaptureStart, file is not opened for writing: %w", ErrUnsupportedOperation)
+	}
+	hasStart := pcap.h.flags&FlagCaptureStart != 0
+	if !hasStart && !pcap.IsEmpty() {
+		return errors.New("cannot set capture start after packets have been written")
+	}
+	if !hasStart && extFileSize+pcap.h.extSize()+captureStartSize > math.MaxUint16 {
+		return errors.New("cannot set capture start, file header would exceed 65535 bytes")
+	}
+
+	// times of previous packets move with the start in delta mode
+	pcap.prevTime += t.UnixNano() - pcap.h.captureStart
+	pcap.lastTime += t.UnixNano() - pcap.h.captureStart
+	pcap.h.captureStart = t.UnixNano()
+	if hasStart {
+		return pcap.writeHeader()
+	}
+	pcap.h.flags |= FlagCaptureStart
+	return pcap.resizeHeader()
+}
+
+// CaptureStart returns the capture start time stored in the file header
+func (pcap *PCAP) CaptureStart() (time.Time, bool) {
+	if pcap.h.flags&FlagCaptureStart == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, pcap.h.captureStart), true
+}
+
+// AbsoluteTime returns the time the packet was captured, adding its
+// Timestamp in nanoseconds to the capture start of the file it was read
+// from. Without a capture start the Timestamp is taken as is. In files
+// with FlagTimestampDelta the Timestamp holds the raw delta from the
+// previous packet and AbsoluteTime returns the reconstructed time.
+func (p Packet) AbsoluteTime() time.Time {
+	return time.Unix(0, p.start).Add(time.Duration(p.Timestamp))
+}
+
+// Time resolves the Timestamp of the packet against base, a time known to
+// be within about 2.1 seconds of the capture, such as the time the packet
+// was received or the time of the previous packet. Timestamp holds only
+// the low 32 bits of nanoseconds since the capture start, or since the
+// Unix epoch without it, so it wraps around about every 4.3 seconds. Time
+// returns the time nearest to base with these low 32 bits.
+func (p Packet) Time(base time.Time) time.Time {
+	elapsed := base.Sub(time.Unix(0, p.start))
+	// signed difference of the low bits picks the nearest wraparound
+	d := int32(p.Timestamp - uint32(elapsed))
+	return base.Add(time.Duration(d))
+}
+
+// NormalizeTimestamp resolves ts, the low 32 bits of nanoseconds since the
+// Unix epoch, against base like Packet.Time, for timestamps of files
+// without a capture start.
+func NormalizeTimestamp(ts uint32, base time.Time) time.Time {
+	return Packet{Timestamp: ts}.Time(base)
+}
+
+// WritePacketTime writes p captured at t, setting its Timestamp from t.
+// In files with FlagTimestampDelta the Timestamp is the delta from the
+// previous packet, which must fit a signed 32-bit integer, and the capture
+// start of an empty file without one is set to t. Otherwise it is the low
+// 32 bits of nanoseconds since the capture start.
+func (pcap *PCAP) WritePacketTime(p Packet, t time.Time) (int, error) {
+	if pcap.h.flags&FlagTimestampDelta == 0 {
+		p.Timestamp = uint32(t.UnixNano() - pcap.h.captureStart)
+		return pcap.WritePacket(p)
+	}
+	ts, err := pcap.deltaTimestamp(t)
+	if err != nil {
+		return 0, err
+	}
+	p.Timestamp = ts
+	return pcap.WritePacket(p)
+}
+
+// deltaTimestamp returns the timestamp of a packet captured at t written
+// next in delta mode. The capture start of an empty file without one is
+// set to t.
+func (pcap *PCAP) deltaTimestamp(t time.Time) (uint32, error) {
+	if pcap.writable && pcap.IsEmpty() && pcap.h.captureStart == 0 {
+		if err := pcap.SetCaptureStart(t); err != nil {
+			return 0, err
+		}
+	}
+	d := t.UnixNano() - pcap.lastTime
+	if d < math.MinInt32 || d > math.MaxInt32 {
+		return 0, fmt.Errorf("timestamp delta %v from the previous packet does not fit 32 bits", time.Duration(d))
+	}
+	return uint32(int32(d)), nil
+}
+
+// timeAt returns the time of the last packet before offset in delta mode,
+// or the capture start if there is none, by summing deltas from the
+// beginning of its capture
+func (pcap *PCAP) timeAt(offset int64) (int64, error) {
+	fh, err := readFileHeader(pcap.rd, 0, atomic.LoadInt64(&pcap.fsize))
+	if err != nil {
+		return 0, err
+	}
+	t := fh.captureStart
+	err = pcap.scanFrom(int64(fh.size), fh, t, func(info PacketInfo, fh *fileHeader) error {
+		t = info.prev
+		if info.Offset >= offset {
+			return errStopScan
+		}
+		if fh.flags&FlagTimestampDelta != 0 {
+			t += int64(int32(info.Timestamp))
+		}
+		return nil
+	})
+	if err != nil && err != errStopScan {
+		return 0, err
+	}
+	return t, nil
+}
+
+// seekTo moves the read offset to off, resolving the time of the previous
+// packet in delta mode
+func (pcap *PCAP) seekTo(off int64) error {
+	if pcap.h.flags&FlagTimestampDelta != 0 {
+		t, err := pcap.timeAt(off)
+		if err != nil {
+			return err
+		}
+		pcap.prevTime = t
+	}
+	atomic.StoreInt64(&pcap.offset, off)
+	pcap.resetReadAhead()
+	return nil
+}
+
+// seekPacket moves the read offset to the packet of info found by a scan
+func (pcap *PCAP) seekPacket(info PacketInfo) {
+	pcap.prevTime = info.prev
+	atomic.StoreInt64(&pcap.offset, info.Offset)
+	pcap.resetReadAhead()
+}
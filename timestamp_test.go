@@ -0,0 +1,362 @@
+package lpcap
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureStart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "start")
+	pcap, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, pcap.SetCaptureStart(start.Add(-time.Hour)))
+	offsets := []time.Duration{0, time.Millisecond, 2500 * time.Millisecond}
+	for _, off := range offsets {
+		_, err := pcap.WritePacket(Packet{
+			PacketType: PacketTypeUnicast,
+			Timestamp:  uint32(off),
+			Len:        1,
+			Data:       []byte{0},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// header keeps its size, so the start can still be corrected
+	assert.NoError(t, pcap.SetCaptureStart(start))
+	pcap.Close()
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pcap.Close()
+	got, ok := pcap.CaptureStart()
+	assert.True(t, ok)
+	assert.True(t, start.Equal(got))
+
+	p := new(Packet)
+	for _, off := range offsets {
+		if _, err := pcap.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		assert.True(t, start.Add(off).Equal(p.AbsoluteTime()))
+	}
+}
+
+func TestCaptureStartAfterPackets(t *testing.T) {
+	pcap := NewMemory()
+	_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, pcap.SetCaptureStart(time.Now()))
+	_, ok := pcap.CaptureStart()
+	assert.False(t, ok)
+}
+
+func TestPacketTime(t *testing.T) {
+	const wrap = time.Duration(1 << 32)
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := Packet{start: start.UnixNano()}
+	for _, elapsed := range []time.Duration{
+		0,
+		time.Second,
+		wrap - 1,
+		wrap,
+		wrap + time.Millisecond,
+		10*wrap + 3*time.Second,
+	} {
+		want := start.Add(elapsed)
+		p.Timestamp = uint32(elapsed)
+		for _, skew := range []time.Duration{0, -2 * time.Second, 2 * time.Second} {
+			assert.True(t, want.Equal(p.Time(want.Add(skew))), "elapsed %v, skew %v", elapsed, skew)
+		}
+	}
+
+	// base after the wraparound, timestamp before it
+	p.Timestamp = uint32(wrap - time.Millisecond)
+	got := p.Time(start.Add(wrap + time.Millisecond))
+	assert.True(t, start.Add(wrap-time.Millisecond).Equal(got))
+
+	// base before the wraparound, timestamp after it
+	p.Timestamp = uint32(time.Millisecond)
+	got = p.Time(start.Add(wrap - time.Millisecond))
+	assert.True(t, start.Add(wrap+time.Millisecond).Equal(got))
+}
+
+func TestNormalizeTimestamp(t *testing.T) {
+	now := time.Date(2022, 5, 1, 12, 0, 0, 123, time.UTC)
+	ts := uint32(now.UnixNano())
+	assert.True(t, now.Equal(NormalizeTimestamp(ts, now)))
+	assert.True(t, now.Equal(NormalizeTimestamp(ts, now.Add(-2*time.Second))))
+	assert.True(t, now.Equal(NormalizeTimestamp(ts, now.Add(2*time.Second))))
+	// beyond half of the wraparound period the nearest time is a different one
+	assert.False(t, now.Equal(NormalizeTimestamp(ts, now.Add(3*time.Second))))
+}
+
+func TestTimestampDeltas(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deltas")
+	pcap, err := Create(path, WithTimestampDeltas())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	// several minutes in total with gaps below the delta limit,
+	// including one going backwards
+	var times []time.Time
+	at := start
+	for i := 0; i < 200; i++ {
+		at = at.Add(time.Duration(i%7)*300*time.Millisecond + time.Duration(i))
+		if i == 50 {
+			at = at.Add(-time.Second)
+		}
+		times = append(times, at)
+	}
+	for i, at := range times {
+		p := Packet{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{byte(i)}}
+		if _, err := pcap.WritePacketTime(p, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.Greater(t, times[len(times)-1].Sub(start), time.Minute)
+	_, err = pcap.WritePacketTime(Packet{PacketType: PacketTypeUnicast}, at.Add(3*time.Second))
+	assert.Error(t, err)
+	assert.NoError(t, pcap.Close())
+
+	pcap, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := pcap.CaptureStart()
+	assert.True(t, ok)
+	assert.True(t, start.Equal(got))
+	packets, err := pcap.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, packets, len(times))
+	for i, p := range packets {
+		assert.True(t, times[i].Equal(p.AbsoluteTime()), "packet %d", i)
+	}
+
+	// seeking into the middle resolves the time of preceding packets
+	off := pcap.DataOffset()
+	for i := 0; i < 100; i++ {
+		off += int64(createdPacketSize) + int64(packets[i].Len)
+	}
+	if _, err := pcap.SeekOffset(off, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, times[100].Equal(p.AbsoluteTime()))
+	assert.NoError(t, pcap.Close())
+
+	// appended packets continue from the last one
+	pcap, err = OpenRW(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := times[len(times)-1].Add(time.Second)
+	if _, err := pcap.WritePacketTime(Packet{PacketType: PacketTypeUnicast}, next); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, pcap.Close())
+	packets, err = ReadAllFrom(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, next.Equal(packets[len(packets)-1].AbsoluteTime()))
+}
+
+// createDeltas returns a capture with timestamp deltas of packets written
+// every second from start, the data of every packet is its number
+func createDeltas(t *testing.T, start time.Time, n int, opts ...Option) []byte {
+	pcap := NewMemory(append([]Option{WithTimestampDeltas(), WithSummaryFooter()}, opts...)...)
+	for i := 0; i < n; i++ {
+		p := Packet{Index: uint16(n - i), PacketType: PacketTypeUnicast, Len: 1, Data: []byte{byte(i)}}
+		if _, err := pcap.WritePacketTime(p, start.Add(time.Duration(i)*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	assert.NoError(t, pcap.Close())
+	return pcap.Bytes()
+}
+
+func TestTimestampDeltasSeek(t *testing.T) {
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	rd, err := OpenMemory(createDeltas(t, start, 5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(i int) time.Time { return start.Add(time.Duration(i) * time.Second) }
+
+	// packets are sorted by Index, which is in reverse of the file order
+	var times []time.Time
+	err = rd.SortedForEach(func(a, b PacketInfo) bool {
+		return a.Index < b.Index
+	}, func(p *Packet) error {
+		times = append(times, p.AbsoluteTime().UTC())
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{at(4), at(3), at(2), at(1), at(0)}, times)
+
+	var mx sync.Mutex
+	got := make(map[byte]time.Time)
+	err = rd.ParallelForEach(3, func(p *Packet) error {
+		mx.Lock()
+		defer mx.Unlock()
+		got[p.Data[0]] = p.AbsoluteTime()
+		return nil
+	})
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		assert.True(t, at(i).Equal(got[byte(i)]), "packet %d", i)
+	}
+
+	// seek into the payload of the second packet and resync to the third
+	off := rd.DataOffset() + createdPacketSize + 1 + createdPacketSize
+	if _, err := rd.SeekOffset(off, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rd.Resync(); err != nil {
+		t.Fatal(err)
+	}
+	p := new(Packet)
+	if _, err := rd.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []byte{2}, p.Data)
+	assert.True(t, at(2).Equal(p.AbsoluteTime()))
+}
+
+func TestTimestampDeltasSummary(t *testing.T) {
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	b := createDeltas(t, start, 5)
+	rd, err := OpenMemory(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := rd.Summary()
+	assert.NoError(t, err)
+	assert.Equal(t, 4*time.Second, s.Duration())
+	assert.Equal(t, 5, s.Count)
+
+	// scanned without the footer
+	rd, err = OpenMemory(b[:len(b)-footerSize])
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = rd.Summary()
+	assert.NoError(t, err)
+	assert.Equal(t, 4*time.Second, s.Duration())
+	counts, err := rd.RateHistogram(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, []int{1, 1, 1, 1, 1}, counts)
+}
+
+func TestTimestampDeltasExport(t *testing.T) {
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	rd, err := OpenMemory(createDeltas(t, start, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for i := 0; i < 3; i++ {
+		want = append(want, strconv.FormatUint(uint64(uint32(time.Duration(i)*time.Second)), 10))
+	}
+
+	// timestamps are the elapsed times of Summary, not the raw deltas
+	for _, payload := range []bool{false, true} {
+		var b bytes.Buffer
+		assert.NoError(t, rd.ExportCSV(&b, payload))
+		rows, err := csv.NewReader(&b).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, row := range rows[1:] {
+			got = append(got, row[2])
+		}
+		assert.Equal(t, want, got)
+	}
+	var b bytes.Buffer
+	assert.NoError(t, rd.ExportText(&b))
+	for i, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		assert.Equal(t, want[i], strings.Fields(line)[1])
+	}
+}
+
+func TestTimestampDeltasAuto(t *testing.T) {
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	pcap := NewMemory(WithTimestampDeltas(), WithAutoTimestamp(start, 1500*time.Millisecond))
+	for i := 0; i < 4; i++ {
+		_, err := pcap.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{byte(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, ok := pcap.CaptureStart()
+	assert.True(t, ok)
+	assert.True(t, start.Equal(got))
+
+	rd, err := OpenMemory(pcap.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	packets, err := rd.ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range packets {
+		assert.True(t, start.Add(time.Duration(i)*1500*time.Millisecond).Equal(p.AbsoluteTime()), "packet %d", i)
+	}
+
+	// steps beyond the delta range are rejected
+	pcap = NewMemory(WithTimestampDeltas(), WithAutoTimestamp(start, 3*time.Second))
+	_, err = pcap.WritePacket(Packet{PacketType: PacketTypeUnicast})
+	assert.NoError(t, err)
+	_, err = pcap.WritePacket(Packet{PacketType: PacketTypeUnicast})
+	assert.Error(t, err)
+}
+
+func TestTimestampDeltasDrainTo(t *testing.T) {
+	start := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+	pcap := NewMemory(WithTimestampDeltas())
+	for i := 0; i < 3; i++ {
+		_, err := pcap.WritePacketTime(Packet{PacketType: PacketTypeUnicast}, start.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := pcap.DrainTo(NewMemory()); err != nil {
+		t.Fatal(err)
+	}
+
+	// deltas of the drained packets are not continued
+	at := start.Add(time.Second)
+	if _, err := pcap.WritePacketTime(Packet{PacketType: PacketTypeUnicast}, at); err != nil {
+		t.Fatal(err)
+	}
+	p := new(Packet)
+	if _, err := pcap.ReadPacket(p); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, at.Equal(p.AbsoluteTime()))
+}
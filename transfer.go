@@ -0,0 +1,97 @@
+// Copyright (c) 2022 0x9ef. All rights reserved.
+// Use of this source code is governed by an MIT license
+// that can be found in the LICENSE file.
+package lpcap
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+type truncater interface {
+	Truncate(size int64) error
+}
+
+// DrainTo moves all packets from the current offset to dst and then
+// truncates the file to its header, so every packet is consumed only once.
+// The file must be created for writing and support truncation, otherwise
+// nothing is moved. Returns the count of moved packets.
+func (pcap *PCAP) DrainTo(dst *PCAP) (int, error) {
+	t, ok := pcap.rd.(truncater)
+	if !pcap.writable || !ok {
+		return 0, fmt.Errorf("cannot call DrainTo, file does not support truncation: %w", ErrUnsupportedOperation)
+	}
+
+	var (
+		count int
+		buf   []byte
+	)
+	pr := pcap.newProgress(atomic.LoadInt64(&pcap.fsize))
+	p := new(Packet)
+	for pcap.Next() {
+		if _, err := pcap.ReadPacket(p); err != nil {
+			return count, err
+		}
+		// detach payload from the packet pool before writing it back
+		buf = append(buf[:0], p.Data...)
+		p.Data = buf
+		if _, err := dst.WritePacket(*p); err != nil {
+			return count, err
+		}
+		count++
+		pr.update(atomic.LoadInt64(&pcap.offset))
+	}
+
+	size := int64(pcap.h.size)
+	if err := t.Truncate(size); err != nil {
+		pcap.lasterr = ErrWrite
+		return count, err
+	}
+	// move the write position back to the end of the truncated file
+	if s, ok := pcap.rd.(io.Seeker); ok {
+		if _, err := s.Seek(size, io.SeekStart); err != nil {
+			pcap.lasterr = ErrWrite
+			return count, err
+		}
+	}
+	atomic.StoreInt64(&pcap.fsize, size)
+	atomic.StoreInt64(&pcap.offset, size)
+	pcap.resetReadAhead()
+	pcap.h.packetCount = 0
+	pcap.summary = Summary{}
+	pcap.prevTime, pcap.lastTime = pcap.h.captureStart, pcap.h.captureStart
+	if pcap.h.flags&FlagPacketCount != 0 {
+		if err := pcap.writeCount(); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// Transform reads packets of src from its current offset, calls fn to
+// modify every packet in place and writes the result to dst. Len is
+// recomputed from Data after fn returns. Stops on the first error of fn.
+// Returns the count of written packets.
+func Transform(dst, src *PCAP, fn func(*Packet) error) (int, error) {
+	count := 0
+	pr := src.newProgress(atomic.LoadInt64(&src.fsize))
+	p := &Packet{Data: []byte{}}
+	for src.Next() {
+		if _, err := src.readPacket(p, p.Data); err != nil {
+			return count, err
+		}
+		buf := p.Data
+		if err := fn(p); err != nil {
+			return count, err
+		}
+		p.Len = uint32(len(p.Data))
+		if _, err := dst.WritePacket(*p); err != nil {
+			return count, err
+		}
+		count++
+		p.Data = buf
+		pr.update(atomic.LoadInt64(&src.offset))
+	}
+	return count, nil
+}
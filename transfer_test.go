@@ -0,0 +1,187 @@
+package lpcap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrainTo(t *testing.T) {
+	dir := t.TempDir()
+	src, err := Create(filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := Create(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	for i := 0; i < 5; i++ {
+		_, err := src.WritePacket(Packet{
+			Index:      uint16(i),
+			PacketType: PacketTypeUnicast,
+			Timestamp:  uint32(time.Now().UnixNano()),
+			Len:        4,
+			Data:       []byte{byte(i), 1, 2, 3},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	n, err := src.DrainTo(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 5, n)
+	assert.False(t, src.Next())
+
+	s, err := os.Stat(filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(createdHeaderSize), s.Size())
+
+	for i := 0; i < 5; i++ {
+		p := new(Packet)
+		if _, err := dst.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, uint16(i), p.Index)
+		assert.Equal(t, []byte{byte(i), 1, 2, 3}, p.Data)
+	}
+	assert.False(t, dst.Next())
+}
+
+func TestDrainToReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	src, err := Create(filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	src.Close()
+	src, err = Open(filepath.Join(dir, "src"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	dst, err := Create(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	_, err = src.DrainTo(dst)
+	assert.Error(t, err)
+}
+
+func TestTransform(t *testing.T) {
+	dir := t.TempDir()
+	src := createSequence(t, 10)
+	defer src.Close()
+	dst, err := Create(filepath.Join(dir, "dst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+
+	// redact payloads of odd packets
+	n, err := Transform(dst, src, func(p *Packet) error {
+		if p.Index%2 == 1 {
+			p.Data = nil
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 10, n)
+
+	for i := 0; i < 10; i++ {
+		p := new(Packet)
+		if _, err := dst.ReadPacket(p); err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, uint16(i), p.Index)
+		if i%2 == 1 {
+			assert.Equal(t, uint32(0), p.Len)
+			assert.Empty(t, p.Data)
+		} else {
+			assert.Equal(t, uint32(4), p.Len)
+			assert.Equal(t, []byte{byte(i), 0, 0, 0}, p.Data)
+		}
+	}
+	assert.False(t, dst.Next())
+}
+
+func TestDrainToPacketCount(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "src")
+	src, err := Create(path, WithPacketCount())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		_, err := src.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{byte(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	dst := NewMemory()
+	n, err := src.DrainTo(dst)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	n, err = src.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	rd, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = rd.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, int64(0), rd.h.packetCount)
+	rd.Close()
+
+	_, err = src.WritePacket(Packet{PacketType: PacketTypeUnicast, Len: 1, Data: []byte{9}})
+	assert.NoError(t, err)
+	assert.NoError(t, src.Close())
+	rd, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rd.Close()
+	n, err = rd.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestDrainToSummaryFooter(t *testing.T) {
+	src := NewMemory(WithSummaryFooter())
+	for i := 0; i < 3; i++ {
+		_, err := src.WritePacket(Packet{PacketType: PacketTypeUnicast, Timestamp: uint32(i + 1), Len: 1, Data: []byte{byte(i)}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := src.DrainTo(NewMemory()); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, src.Close())
+
+	rd, err := OpenMemory(src.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := rd.Summary()
+	assert.NoError(t, err)
+	assert.Equal(t, Summary{}, s)
+	assert.False(t, rd.Next())
+}